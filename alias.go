@@ -0,0 +1,274 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"unsafe"
+)
+
+// Alias describes a piece of memory reachable from both of the supplied roots
+type Alias struct {
+	Kind    reflect.Kind // kind of the shared entity: Pointer, Map, Chan or Slice
+	Type    string       // type of the shared entity (as seen from the first root)
+	Address uintptr      // address of the shared memory
+	PathA   string       // access path from the first root
+	PathB   string       // access path from the second root
+}
+
+func (a Alias) String() string {
+	return fmt.Sprintf("%s (%s) at 0x%x: %s and %s", a.Kind, a.Type, a.Address, a.PathA, a.PathB)
+}
+
+type (
+	// reference is a single pointer/map/chan/slice encountered during the walk
+	reference struct {
+		kind reflect.Kind
+		typ  reflect.Type
+		from uintptr // start of the referenced memory
+		to   uintptr // end of the referenced memory (pointers and slices only)
+		path string
+	}
+
+	aliasWalker struct {
+		visited    map[visitKey]bool
+		seen       map[visitKey]bool
+		references []reference
+	}
+
+	visitKey struct {
+		addr uintptr
+		typ  reflect.Type
+	}
+)
+
+// SharedReferences returns every pointer, map, chan and (overlapping) slice backing array
+// reachable from both a and b, along with the access paths from each of the roots
+func SharedReferences(a, b interface{}) []Alias {
+	left := walkReferences(a, "a")
+	right := walkReferences(b, "b")
+
+	index := indexReferences(right)
+
+	var found []Alias
+	for _, l := range left {
+		for _, r := range index.overlapping(l) {
+			found = append(found, Alias{
+				Kind:    l.kind,
+				Type:    l.typ.String(),
+				Address: maxAddr(l.from, r.from),
+				PathA:   l.path,
+				PathB:   r.path,
+			})
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].PathA != found[j].PathA {
+			return found[i].PathA < found[j].PathA
+		}
+		return found[i].PathB < found[j].PathB
+	})
+	return found
+}
+
+func walkReferences(root interface{}, name string) []reference {
+	walker := &aliasWalker{visited: map[visitKey]bool{}, seen: map[visitKey]bool{}}
+	walker.walk(reflect.ValueOf(root), name, 0)
+	return walker.references
+}
+
+// referenceIndex finds the references overlapping a given one without comparing it to all of them
+type referenceIndex struct {
+	exact  map[exactKey][]int // maps and chans: by their address
+	ranges []int              // pointers and slices: ordered by the start of their memory
+	maxTo  []uintptr          // the largest end of the memory among ranges[:i+1]
+	refs   []reference
+}
+
+type exactKey struct {
+	kind reflect.Kind
+	addr uintptr
+}
+
+func indexReferences(refs []reference) *referenceIndex {
+	index := &referenceIndex{exact: map[exactKey][]int{}, refs: refs}
+	for i, ref := range refs {
+		if ref.to != 0 {
+			index.ranges = append(index.ranges, i)
+		} else {
+			key := exactKey{ref.kind, ref.from}
+			index.exact[key] = append(index.exact[key], i)
+		}
+	}
+
+	sort.SliceStable(index.ranges, func(i, j int) bool {
+		return refs[index.ranges[i]].from < refs[index.ranges[j]].from
+	})
+	index.maxTo = make([]uintptr, len(index.ranges))
+	var maxTo uintptr
+	for i, ref := range index.ranges {
+		maxTo = max(maxTo, refs[ref].to)
+		index.maxTo[i] = maxTo
+	}
+	return index
+}
+
+// overlapping returns the indexed references sharing memory with the supplied one, in their original order
+func (index *referenceIndex) overlapping(l reference) []reference {
+	if l.to == 0 {
+		var found []reference
+		for _, i := range index.exact[exactKey{l.kind, l.from}] {
+			found = append(found, index.refs[i])
+		}
+		return found
+	}
+
+	// pointers and slice backing arrays: any overlap of the memory ranges
+	end := sort.Search(len(index.ranges), func(i int) bool {
+		return index.refs[index.ranges[i]].from >= l.to
+	})
+	var matches []int
+	for i := end - 1; i >= 0 && index.maxTo[i] > l.from; i-- {
+		if ref := index.ranges[i]; index.refs[ref].to > l.from {
+			matches = append(matches, ref)
+		}
+	}
+	sort.Ints(matches)
+
+	found := make([]reference, len(matches))
+	for i, ref := range matches {
+		found[i] = index.refs[ref]
+	}
+	return found
+}
+
+func maxAddr(a, b uintptr) uintptr {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+const maxAliasDepth = 1000
+
+func (w *aliasWalker) walk(val reflect.Value, path string, depth int) {
+	if !val.IsValid() || depth > maxAliasDepth {
+		return
+	}
+
+	if val.CanAddr() {
+		key := visitKey{val.UnsafeAddr(), val.Type()}
+		if w.visited[key] {
+			return
+		}
+		w.visited[key] = true
+	}
+
+	switch val.Kind() {
+	case reflect.Pointer:
+		if val.IsNil() {
+			return
+		}
+		size := val.Type().Elem().Size()
+		if size == 0 {
+			size = 1
+		}
+		if !w.remember(val, val.Pointer(), val.Pointer()+size, path) {
+			return
+		}
+		w.walk(val.Elem(), "(*"+path+")", depth+1)
+
+	case reflect.Interface:
+		if !val.IsNil() {
+			w.walk(val.Elem(), path, depth+1)
+		}
+
+	case reflect.Map:
+		if val.IsNil() {
+			return
+		}
+		if !w.remember(val, val.Pointer(), 0, path) {
+			return
+		}
+		iter := val.MapRange()
+		for iter.Next() {
+			entry := path + "[" + keyText(iter.Key()) + "]"
+			w.walk(iter.Key(), entry+"(key)", depth+1)
+			w.walk(iter.Value(), entry, depth+1)
+		}
+
+	case reflect.Chan:
+		if !val.IsNil() {
+			w.remember(val, val.Pointer(), 0, path)
+		}
+
+	case reflect.Slice:
+		if val.IsNil() || val.Cap() == 0 {
+			return
+		}
+		size := uintptr(val.Cap()) * val.Type().Elem().Size()
+		if size == 0 {
+			return
+		}
+		w.remember(val, val.Pointer(), val.Pointer()+size, path)
+		for index := 0; index < val.Len(); index++ {
+			w.walk(val.Index(index), path+"["+strconv.Itoa(index)+"]", depth+1)
+		}
+
+	case reflect.Array:
+		for index := 0; index < val.Len(); index++ {
+			w.walk(val.Index(index), path+"["+strconv.Itoa(index)+"]", depth+1)
+		}
+
+	case reflect.Struct:
+		typ := val.Type()
+		for index := 0; index < typ.NumField(); index++ {
+			w.walk(val.Field(index), path+"."+typ.Field(index).Name, depth+1)
+		}
+	}
+}
+
+// remember records the reference; returns false if the memory has already been seen (from this root)
+func (w *aliasWalker) remember(val reflect.Value, from, to uintptr, path string) bool {
+	key := visitKey{from, val.Type()}
+	if val.Kind() != reflect.Slice {
+		if w.seen[key] {
+			return false
+		}
+		w.seen[key] = true
+	}
+
+	w.references = append(w.references, reference{
+		kind: val.Kind(),
+		typ:  val.Type(),
+		from: from,
+		to:   to,
+		path: path,
+	})
+	return true
+}
+
+func keyText(key reflect.Value) string {
+	switch key.Kind() {
+	case reflect.String:
+		return strconv.Quote(key.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(key.Bool())
+	}
+
+	if key.CanInterface() {
+		return fmt.Sprintf("%v", key.Interface())
+	}
+	if key.CanAddr() {
+		return fmt.Sprintf("%v", reflect.NewAt(key.Type(), unsafe.Pointer(key.UnsafeAddr())).Elem().Interface())
+	}
+	return key.Type().String()
+}
@@ -4,14 +4,20 @@ package memory
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 func (m *mapper) mapPtrIface(iVal reflect.Value, parentID nodeID, inlineable bool, isPointer bool) (nodeID, string) {
 	pointee := iVal.Elem()
+	if kind := pointee.Kind(); !isPointer && (kind == reflect.Struct || kind == reflect.Array) {
+		// the value stored in an interface is not addressable
+		pointee = m.addressable(pointee)
+	}
 
 	if pointee.IsValid() && !pointee.IsZero() {
 		return m.mapValue(pointee, parentID, inlineable) // todo: recursion?
@@ -23,10 +29,10 @@ func (m *mapper) mapPtrIface(iVal reflect.Value, parentID nodeID, inlineable boo
 		*/
 	}
 
-	key := getNodeKey(iVal)
+	key := m.getNodeKey(iVal)
 
-	describe("map.ptr.from", iVal)
-	describe("map.ptr.to", pointee)
+	m.describe("map.ptr.from", iVal)
+	m.describe("map.ptr.to", pointee)
 
 	// inlineable=false so an invalid parentID is fine
 	pointeeNode, pointeeSummary := m.mapValue(pointee, 0, inlineable) // false
@@ -55,30 +61,13 @@ func (m *mapper) mapPtrIface(iVal reflect.Value, parentID nodeID, inlineable boo
 }
 
 func (m *mapper) mapString(stringVal reflect.Value, inlineable bool) (nodeID, string) {
-	// We want the output to look like a Go quoted string literal. The first
-	// Quote achieves that. The second is to quote it for graphviz itself.
-	quoted := stringVal.String()
-	quoted = strings.Trim(quoted, "\"")
-	quoted = strconv.Quote(quoted)
-	/*
-
-		todo: revisit this commented out block
-
-		quoted = strconv.Quote(quoted)
-		quoted = strconv.Quote(quoted)
-
-		quoted = strings.ReplaceAll(strings.ReplaceAll(quoted, "<", "\\<"), ">", "\\>")
-
-		// Lastly, quoting adds quotation-marks around the string, but it is
-		// inserted into a graphviz string literal, so we have to remove those.
-		quoted = quoted[1 : len(quoted)-1]
-	*/
-	quoted = normalizeString(quoted)
+	// We want the output to look like a Go quoted string literal (see quoteString)
+	quoted := quoteString(stringVal.String())
 
 	if inlineable {
 		return 0, quoted
 	}
-	m.nodeSummaries[getNodeKey(stringVal)] = "string"
+	m.nodeSummaries[m.getNodeKey(stringVal)] = "string"
 	return m.newBasicNode(stringVal, quoted), "string"
 }
 
@@ -87,7 +76,7 @@ func (m *mapper) mapBool(stringVal reflect.Value, inlineable bool) (nodeID, stri
 	if inlineable {
 		return 0, value
 	}
-	m.nodeSummaries[getNodeKey(stringVal)] = "bool"
+	m.nodeSummaries[m.getNodeKey(stringVal)] = "bool"
 	return m.newBasicNode(stringVal, value), "bool"
 }
 
@@ -96,7 +85,7 @@ func (m *mapper) mapInt(numVal reflect.Value, inlineable bool) (nodeID, string)
 	if inlineable {
 		return 0, printed
 	}
-	m.nodeSummaries[getNodeKey(numVal)] = "int"
+	m.nodeSummaries[m.getNodeKey(numVal)] = "int"
 	return m.newBasicNode(numVal, printed), "int"
 }
 
@@ -105,15 +94,151 @@ func (m *mapper) mapUint(numVal reflect.Value, inlineable bool) (nodeID, string)
 	if inlineable {
 		return 0, printed
 	}
-	m.nodeSummaries[getNodeKey(numVal)] = "uint"
+	m.nodeSummaries[m.getNodeKey(numVal)] = "uint"
 	return m.newBasicNode(numVal, printed), "uint"
 }
 
+func (m *mapper) mapFloat(numVal reflect.Value, inlineable bool) (nodeID, string) {
+	value, bits := numVal.Float(), numVal.Type().Bits()
+	format, precision := floatFormat()
+	printed := strconv.FormatFloat(value, format, precision, bits)
+	if Options().FloatHexBits {
+		printed += " (" + floatBits(value, bits) + ")"
+	}
+	if inlineable {
+		return 0, printed
+	}
+	m.nodeSummaries[m.getNodeKey(numVal)] = "float"
+	return m.newBasicNode(numVal, printed), "float"
+}
+
+func (m *mapper) mapComplex(numVal reflect.Value, inlineable bool) (nodeID, string) {
+	value, bits := numVal.Complex(), numVal.Type().Bits()
+	format, precision := floatFormat()
+	printed := strconv.FormatComplex(value, format, precision, bits)
+	if Options().FloatHexBits {
+		printed += " (" + floatBits(real(value), bits/2) + ", " + floatBits(imag(value), bits/2) + ")"
+	}
+	if inlineable {
+		return 0, printed
+	}
+	m.nodeSummaries[m.getNodeKey(numVal)] = "complex"
+	return m.newBasicNode(numVal, printed), "complex"
+}
+
+// floatFormat returns the format (see strconv.FormatFloat) and the precision configured for the floats
+func floatFormat() (byte, int) {
+	options := Options()
+	format := byte('g')
+	if len(options.FloatFormat) == 1 && strings.Contains("beEfgGx", options.FloatFormat) {
+		format = options.FloatFormat[0]
+	}
+	return format, options.FloatPrecision
+}
+
+// floatBits returns the IEEE 754 bit pattern of the float, in hex
+func floatBits(value float64, bits int) string {
+	if bits == 32 {
+		return "0x" + strconv.FormatUint(uint64(math.Float32bits(float32(value))), 16)
+	}
+	return "0x" + strconv.FormatUint(math.Float64bits(value), 16)
+}
+
+// isSpecialNumber reports whether the value is (or has a part which is) NaN or infinite
+func isSpecialNumber(value reflect.Value) bool {
+	special := func(f float64) bool { return math.IsNaN(f) || math.IsInf(f, 0) }
+	if value.CanFloat() {
+		return special(value.Float())
+	}
+	if value.CanComplex() {
+		c := value.Complex()
+		return special(real(c)) || special(imag(c))
+	}
+	return false
+}
+
+func (m *mapper) mapUnsafePointer(ptrVal reflect.Value, inlineable bool) (nodeID, string) {
+	printed := "0x" + strconv.FormatUint(uint64(ptrVal.Pointer()), 16)
+	if inlineable {
+		return 0, printed
+	}
+	m.nodeSummaries[m.getNodeKey(ptrVal)] = "unsafe.Pointer"
+	return m.newBasicNode(ptrVal, printed), "unsafe.Pointer"
+}
+
+// capturedAt returns the node (already mapped) the unsafe.Pointer/uintptr value points to, 0 if none.
+// when several nodes share the address (e.g. a struct and its first field) the largest one wins
+func (m *mapper) capturedAt(val reflect.Value) nodeID {
+	var addr uintptr
+	switch val.Kind() {
+	case reflect.UnsafePointer:
+		addr = val.Pointer()
+	case reflect.Uintptr:
+		addr = uintptr(val.Uint())
+	default:
+		return 0
+	}
+	if addr == 0 {
+		return 0
+	}
+
+	found, size := nodeID(0), uintptr(0)
+	if m.parent != nil {
+		// the nodes mapped by the parent (indexed before the sub-mappers were started, see prefetch)
+		found, size = m.parent.largestAt(addr, found, size)
+	}
+	m.indexAddresses()
+	found, _ = m.largestAt(addr, found, size)
+	return found
+}
+
+// largestAt returns the largest of the (supplied and the) nodes at the address; the node identified first wins a tie
+func (m *mapper) largestAt(addr uintptr, found nodeID, size uintptr) (nodeID, uintptr) {
+	for _, key := range m.addresses[addr] {
+		id := m.nodeIDs[key]
+		if id == 0 {
+			continue
+		}
+		var current uintptr
+		if key.typ != nil {
+			current = key.typ.Size()
+		}
+		if found == 0 || current > size || (current == size && identifiedBefore(id, found)) {
+			found, size = id, current
+		}
+	}
+	return found, size
+}
+
+// identifiedBefore reports whether the node was identified before the other one
+// (the nodes of the sub-mappers come after those of the parent and get decreasing negative ids, see getNodeID)
+func identifiedBefore(id, other nodeID) bool {
+	if (id < 0) != (other < 0) {
+		return id > 0
+	}
+	if id < 0 {
+		return id > other
+	}
+	return id < other
+}
+
+// indexAddresses adds the keys identified since the last call to the addresses
+func (m *mapper) indexAddresses() {
+	for _, key := range m.unindexed {
+		switch key.kind {
+		case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+			m.addresses[key.addr] = append(m.addresses[key.addr], key)
+		default:
+			// not rendered as nodes of their own
+		}
+	}
+	m.unindexed = m.unindexed[:0]
+}
+
 func (m *mapper) mapFunc(funcVal reflect.Value, inlineable bool) (nodeID, string) {
 
 	uType := funcVal.Type()
-	id := m.getNodeID(funcVal)
-	key := getNodeKey(funcVal)
+	id, key := m.nodeOf(funcVal)
 	m.nodeSummaries[key] = escapeString(uType.String())
 
 	if inlineable || !funcVal.IsValid() || funcVal.IsZero() {
@@ -122,6 +247,7 @@ func (m *mapper) mapFunc(funcVal reflect.Value, inlineable bool) (nodeID, string
 
 	funcTypeName := uType.String()
 	snode := createNode(id, funcTypeName, "function")
+	snode.measure(funcVal)
 
 	if funcVal.IsValid() && !funcVal.IsZero() {
 		ptr := funcVal.Pointer()
@@ -160,20 +286,84 @@ func (m *mapper) mapFunc(funcVal reflect.Value, inlineable bool) (nodeID, string
 		// value := fmt.Sprintf("%t", funcVal.Bool())
 		// if inlineable { return 0, value }
 
-		m.nodeSummaries[getNodeKey(funcVal)] = "func"
+		m.nodeSummaries[m.getNodeKey(funcVal)] = "func"
 		return m.newBasicNode(funcVal, value), "func"
 	*/
 }
 
-func normalizeString(original string) string {
-	maxAllowedStringLen := Options().MaxStringLength
+// quoteString quotes the string as a Go literal. the strings longer (in runes) than Settings.MaxStringLength
+// keep only their beginning and end (followed by their original length), the lines get wrapped (see Settings.WrapStrings)
+func quoteString(original string) string {
+	limit := Options().MaxStringLength
+	if limit <= 0 || len(original) <= limit {
+		return quoteLines(original)
+	}
+	runes := utf8.RuneCountInString(original)
+	if runes <= limit {
+		return quoteLines(original)
+	}
+
+	half := max(limit/2-1, 1) // (at least a rune of each end, whatever the limit)
+	head, tail := 0, len(original)
+	for count := 0; count < half; count++ {
+		_, size := utf8.DecodeRuneInString(original[head:])
+		head += size
+	}
+	for count := 0; count < half; count++ {
+		_, size := utf8.DecodeLastRuneInString(original[:tail])
+		tail -= size
+	}
+
+	length := str(len(original)) + " bytes"
+	if runes != len(original) {
+		length += ", " + str(runes) + " runes"
+	}
+	return quoteLines(original[:head]) + ".." + quoteLines(original[tail:]) + " (" + length + ")"
+}
+
+// quoteLines quotes the string, breaking it (at its new lines and every Settings.WrapStrings runes) into several lines
+func quoteLines(original string) string {
+	width := Options().WrapStrings
+	if width <= 0 {
+		return strconv.Quote(original)
+	}
+
+	var lines []string
+	start, count := 0, 0
+	for offset, r := range original {
+		if count == width {
+			lines = append(lines, original[start:offset])
+			start, count = offset, 0
+		}
+		count++
+		if r == '\n' {
+			lines = append(lines, original[start:offset+1])
+			start, count = offset+1, 0
+		}
+	}
+	if start < len(original) || len(lines) == 0 {
+		lines = append(lines, original[start:])
+	}
 
-	if len(original) <= maxAllowedStringLen {
-		return original
+	for index, line := range lines {
+		quoted := strconv.Quote(line)
+		lines[index] = quoted[1 : len(quoted)-1]
 	}
-	half := (maxAllowedStringLen / 2) - 1
+	return "\"" + strings.Join(lines, "\n") + "\""
+}
 
-	return original[:half] + ".." + original[len(original)-half:]
+// fullString returns the (quoted) value of the string whose rendering (see quoteString) got shortened or wrapped, "" otherwise
+func fullString(value reflect.Value, rendered string) string {
+	if value.Kind() != reflect.String {
+		return ""
+	}
+	options := Options()
+	if original := value.String(); len(original) > options.MaxStringLength || options.WrapStrings > 0 {
+		if quoted := strconv.Quote(original); quoted != rendered {
+			return quoted
+		}
+	}
+	return ""
 }
 
 func str(from int) string {
@@ -183,8 +373,7 @@ func str(from int) string {
 func (m *mapper) mapChan(chanVal reflect.Value, inlineable bool) (nodeID, string) {
 
 	uType := chanVal.Type()
-	id := m.getNodeID(chanVal)
-	key := getNodeKey(chanVal)
+	id, key := m.nodeOf(chanVal)
 	m.nodeSummaries[key] = escapeString(uType.String())
 
 	if inlineable {
@@ -193,6 +382,7 @@ func (m *mapper) mapChan(chanVal reflect.Value, inlineable bool) (nodeID, string
 
 	chanTypeName := uType.String()
 	snode := createNode(id, chanTypeName, "channel")
+	snode.measure(chanVal)
 
 	if chanVal.IsValid() && !chanVal.IsNil() && !chanVal.IsZero() {
 		snode.addFieldInlined("len", "len", str(chanVal.Len()), Info)
@@ -231,7 +421,7 @@ func (m *mapper) mapChan(chanVal reflect.Value, inlineable bool) (nodeID, string
 		// value := fmt.Sprintf("%t", funcVal.Bool())
 		// if inlineable { return 0, value }
 
-		m.nodeSummaries[getNodeKey(chanVal)] = "chan"
+		m.nodeSummaries[m.getNodeKey(chanVal)] = "chan"
 		return m.newBasicNode(chanVal, value), "chan"
 	*/
 }
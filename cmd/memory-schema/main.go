@@ -0,0 +1,49 @@
+// github.com/seamia/memory/cmd/memory-schema
+
+// memory-schema draws (as a graphviz digraph) the types declared in the Go package found in the given folder:
+//
+//	memory-schema [-o output.dot] [folder]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seamia/memory"
+)
+
+func main() {
+	output := flag.String("o", "", "output file (default: stdout)")
+	comment := flag.String("comment", "", "comment (label) of the diagram")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if len(*output) > 0 {
+		var err error
+		if f, err = os.Create(*output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		w = f
+	}
+
+	err := memory.MapSource(w, dir, memory.Comment(*comment))
+	if f != nil {
+		// (os.Exit skips the deferred calls: close explicitly, a failure to flush the file is a failure too)
+		if closed := f.Close(); err == nil {
+			err = closed
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
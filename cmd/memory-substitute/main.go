@@ -0,0 +1,60 @@
+// github.com/seamia/memory/cmd/memory-substitute
+
+// memory-substitute generates the "substitute" section of the seamia.memory.options file
+// from the typed constants declared in the Go package found in the given folder:
+//
+//	memory-substitute [-o output.json] [folder]
+//
+// the tables of the bit flags are marked with "(flags)": "true" (see memory.SubstitutesFrom).
+// a type with just the 1 and 2 constants is taken for an enumeration: mark its table by hand if it is a set of flags
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seamia/memory"
+)
+
+func main() {
+	output := flag.String("o", "", "output file (default: stdout)")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	substitute, err := memory.SubstitutesFrom(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if len(*output) > 0 {
+		if f, err = os.Create(*output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		w = f
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	err = encoder.Encode(map[string]interface{}{"substitute": substitute})
+	if f != nil {
+		// (os.Exit skips the deferred calls: close explicitly, a failure to flush the file is a failure too)
+		if closed := f.Close(); err == nil {
+			err = closed
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 	"unsafe"
 )
 
@@ -18,51 +21,37 @@ const (
 
 func (m *mapper) mapStruct(structVal reflect.Value) (nodeID, string) {
 
-	uType := structVal.Type()
-	id := m.getNodeID(structVal)
-	key := getNodeKey(structVal)
-	m.nodeSummaries[key] = escapeString(uType.String())
+	plan := m.planOf(structVal.Type())
+	id, key := m.nodeOf(structVal)
+	m.nodeSummaries[key] = plan.summary
 
-	structTypeName := getStructTypeName(uType)
-	snode := createNode(id, structTypeName, "struct: "+m.nodeSummaries[key])
-
-	if structTypeName == "Object" {
-		debug()
+	if plan.isError && structVal.CanAddr() && m.mapError(id, structVal, errorValue(structVal), plan.name, plan.tooltip) {
+		return id, plan.summary
 	}
 
-	for index := 0; index < uType.NumField(); index++ {
-		fld := structVal.Field(index)
-
-		if structTypeName == "Object" && uType.Field(index).Name == "Decl" {
-			debug()
-		}
+	snode := createNode(id, plan.name, plan.tooltip)
+	snode.measure(structVal)
+	snode.reserve(len(plan.fields))
 
-		m.unified(snode, fld, uType.Field(index).Type, uType.Field(index).Name, index)
+	for index, field := range plan.fields {
+		m.unified(snode, structVal.Field(index), field.typeName, field.path, field.name, index)
 	}
 
 	if showZeroFields || !isEmpty(structVal) || m.isRoot(structVal) {
 		m.addNode(snode)
 	}
-	return id, m.nodeSummaries[key]
+	return id, plan.summary
 }
 
-func (m *mapper) unified(snode *cnode, fld reflect.Value, typ reflect.Type, fieldName string, index int) {
+// unified adds the row for the given field (or element/entry) of the collection; fieldType is getTypeName of its type,
+// path is the "struct:Type.field" of the struct fields (empty for the elements/entries)
+func (m *mapper) unified(snode *cnode, fld reflect.Value, fieldType string, path string, fieldName string, index int) {
 
 	if !fld.CanAddr() {
 		// TODO: when does this happen? Can we work around it?
 		// warning("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA: %s", fld.String())
 		//continue
 	}
-	//anonymous := uType.Field(index).Anonymous
-	// fieldName := uType.Field(index).Name
-	fieldType := getTypeName(typ) // typ.Name()
-
-	fldIsEmpty := isEmpty(fld)
-	if fldIsEmpty {
-		trace("field %s is EMPTY\n", fld.Type().Kind().String())
-	} else {
-		trace("field %s is NOT EMPTY\n", fld.Type().Kind().String())
-	}
 
 	structRef := getStructRef(index)
 
@@ -80,42 +69,68 @@ func (m *mapper) unified(snode *cnode, fld reflect.Value, typ reflect.Type, fiel
 		}
 	*/
 
-	if fld.CanAddr() {
-		fld = reflect.NewAt(fld.Type(), unsafe.Pointer(fld.UnsafeAddr())).Elem()
-	} else {
-		//??????????????????
+	fld = accessible(fld)
+	if text, kind, rendered := m.render(fld); rendered {
+		snode.extra += inlineSize(fld)
+		snode.addFieldInlined(structRef, fieldName, text, kind)
+		return
+	}
+
+	fieldID, summary := m.mapUnrendered(fld, snode.id, m.isInlinableValue(fld))
+	if m.stopped != nil {
+		// the traversal was cancelled: do not add (possibly expensive) rows anymore
+		return
 	}
-	fieldID, summary := m.mapValue(fld, snode.id, isInlinableValue(fld))
 
 	// if fld was inlined (id == 0) then print summary, else just the name and a link to the actual
 	if fieldID == 0 {
-		value, cell, isnil := m.interpretValueType(summary, fieldType, fld)
-		if cell == Default {
-			cell = valueCellType(isnil)
+		value, kind, isnil := m.interpretValueType(summary, fieldType, path, fld)
+		if kind == Default {
+			kind = valueCellType(isnil)
 		}
 
+		snode.extra += inlineSize(fld)
 		if !isnil || showStructNilFields {
-			snode.addFieldInlined(structRef, fieldName, value, cell)
+			if title := fullString(fld, value); len(title) > 0 {
+				snode.addCells(cell{port: structRef, name: fieldName, kind: Key}, cell{name: value, kind: kind, title: title})
+			} else if target := m.capturedAt(fld); target != 0 {
+				// unsafe.Pointer/uintptr holding the address of a mapped value
+				outgoing := getStructOutgoing(index)
+				snode.addCells(cell{port: structRef, name: fieldName, kind: Key}, cell{port: outgoing, name: value, kind: kind})
+				m.addConnection(snode.id, outgoing, target, fieldName, connPointer)
+			} else {
+				snode.addFieldInlined(structRef, fieldName, value, kind)
+			}
 		} else {
 			warning("not showing fld [%s] cause it is nil", fieldName)
 		}
 	} else {
 		// snode.addField(structRef, fieldName, Key)
 
-		if showZeroFields || !fldIsEmpty {
+		if showZeroFields || !isEmpty(fld) {
 			outgoing := getStructOutgoing(index)
-			snode.addCells(cell{structRef, fieldName, Key}, cell{outgoing, fieldType, Type})
+			snode.addCells(cell{port: structRef, name: fieldName, kind: Key}, cell{port: outgoing, name: fieldType, kind: Type})
 
 			m.addConnection(snode.id, outgoing, fieldID, fieldName+"", kind2style(fld.Type().Kind()))
+			if kind := fld.Kind(); kind == reflect.Struct || kind == reflect.Array {
+				m.embedded[fieldID] = true
+			}
 		} else {
 			warning("not showing fld [%s] cause it is empty", fieldName)
 		}
 	}
 }
 
+// accessible returns the (addressable) value without the read-only flag of the unexported fields
+func accessible(fld reflect.Value) reflect.Value {
+	if fld.CanAddr() {
+		return reflect.NewAt(fld.Type(), unsafe.Pointer(fld.UnsafeAddr())).Elem()
+	}
+	return fld
+}
+
 func (m *mapper) mapSlice(sliceVal reflect.Value, parentID nodeID, inlineable bool) (nodeID, string) {
-	sliceID := m.getNodeID(sliceVal)
-	key := getNodeKey(sliceVal)
+	sliceID, key := m.nodeOf(sliceVal)
 	sliceType := escapeString(sliceVal.Type().String())
 	m.nodeSummaries[key] = sliceType
 
@@ -129,8 +144,13 @@ func (m *mapper) mapSlice(sliceVal reflect.Value, parentID nodeID, inlineable bo
 		return m.newBasicNode(sliceVal, m.nodeSummaries[key]), sliceType
 	}
 
+	if sliceVal.Type().Elem().Kind() == reflect.Uint8 && Options().BytesFormat != bytesAsElements {
+		return m.mapBytes(sliceVal, sliceID, sliceType)
+	}
+
 	// inlinableType := isInlinableType(sliceVal.Type())
 	snode := createNode(sliceID, sliceType, "[]")
+	snode.measure(sliceVal)
 
 	// sourceID is the nodeID that links will start from
 	// if inlined then these come from the parent
@@ -146,12 +166,18 @@ func (m *mapper) mapSlice(sliceVal reflect.Value, parentID nodeID, inlineable bo
 	}
 
 	discardedEntries := 0
+	elemType := getTypeName(sliceVal.Type().Elem())
 
-	for index := 0; index < length; index++ {
-		value := sliceVal.Index(index)
-		typ := value.Type()
+	if m.config.workers > 1 {
+		elements := make([]reflect.Value, length)
+		for index := range elements {
+			elements[index] = accessible(sliceVal.Index(index))
+		}
+		m.prefetch(snode.id, elements)
+	}
 
-		m.unified(snode, value, typ, str(index), index)
+	for index := 0; index < length; index++ {
+		m.unified(snode, sliceVal.Index(index), elemType, "", str(index), index)
 
 		_ = sourceID
 	}
@@ -170,11 +196,113 @@ func (m *mapper) mapSlice(sliceVal reflect.Value, parentID nodeID, inlineable bo
 	return sliceID, m.nodeSummaries[key]
 }
 
+const (
+	bytesAsText     = "text"     // quoted (even if not printable)
+	bytesAsHex      = "hex"      // hex dump
+	bytesAsElements = "elements" // a row per byte (like any other slice)
+)
+
+// mapBytes renders the []byte/[N]byte (or alike) as a quoted string when the bytes are printable, as a hex dump otherwise
+// (see Settings.BytesFormat, MaxBytesLength and BytesPerLine)
+func (m *mapper) mapBytes(bytesVal reflect.Value, id nodeID, typeName string) (nodeID, string) {
+	length, totalLength := bytesVal.Len(), bytesVal.Len()
+	snode := createNode(id, typeName+" ("+str(totalLength)+" bytes)", "[]byte")
+	snode.measure(bytesVal)
+
+	if limit := Options().MaxBytesLength; limit > 0 && length > limit {
+		length = limit
+	}
+	data := make([]byte, length)
+	for index := range data {
+		data[index] = byte(bytesVal.Index(index).Uint())
+	}
+
+	switch format := Options().BytesFormat; {
+	case format == bytesAsText || (format != bytesAsHex && printable(data, length < totalLength)):
+		if length < totalLength {
+			// do not cut a rune in half: drop the last one, if incomplete
+			if start := lastRuneStart(data); start >= 0 && !utf8.FullRune(data[start:]) {
+				length = start
+			}
+			data = data[:length]
+		}
+		snode.addFieldInlined("", "text", strconv.Quote(string(data)), Value)
+
+	default:
+		perLine := Options().BytesPerLine
+		if perLine <= 0 {
+			perLine = 16
+		}
+		for offset := 0; offset < len(data); offset += perLine {
+			end := offset + perLine
+			if end > len(data) {
+				end = len(data)
+			}
+			snode.addFieldInlined("", fmt.Sprintf("%04x", offset), hexLine(data[offset:end], perLine), Value)
+		}
+	}
+
+	if totalLength != length {
+		snode.addField("", fmt.Sprintf("%d more bytes ...", totalLength-length), Footer)
+	}
+
+	m.addNode(snode)
+	return id, typeName
+}
+
+// printable reports whether the bytes are (valid UTF-8) text; truncated allows the last rune to be cut short
+func printable(data []byte, truncated bool) bool {
+	for index := 0; index < len(data); {
+		r, size := utf8.DecodeRune(data[index:])
+		if r == utf8.RuneError && size <= 1 {
+			return truncated && !utf8.FullRune(data[index:])
+		}
+		if !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+		index += size
+	}
+	return true
+}
+
+// lastRuneStart returns the index the last (possibly incomplete) rune starts at, -1 if empty
+func lastRuneStart(data []byte) int {
+	start := len(data) - 1
+	for start > 0 && start > len(data)-utf8.UTFMax && !utf8.RuneStart(data[start]) {
+		start--
+	}
+	return start
+}
+
+// hexLine renders a line of the hex dump: the bytes in hex (padded to the full line) followed by their ascii
+func hexLine(data []byte, perLine int) string {
+	var line strings.Builder
+	for index := 0; index < perLine; index++ {
+		if index > 0 && index%8 == 0 {
+			line.WriteByte(' ')
+		}
+		if index < len(data) {
+			fmt.Fprintf(&line, "%02x ", data[index])
+		} else {
+			line.WriteString("   ")
+		}
+	}
+	line.WriteString(" |")
+	for _, b := range data {
+		if b < 0x20 || b > 0x7e {
+			b = '.'
+		}
+		line.WriteByte(b)
+	}
+	line.WriteString("|")
+	return line.String()
+}
+
 func (m *mapper) mapMap(mapVal reflect.Value, parentID nodeID, inlineable bool) (nodeID, string) {
 	// create a string type while escaping graphviz special characters
 	mapType := escapeString(mapVal.Type().String())
 
-	nodeKey := getNodeKey(mapVal)
+	nodeKey := m.getNodeKey(mapVal)
 
 	if mapVal.Len() == 0 {
 		m.nodeSummaries[nodeKey] = mapType + "\\{\\}"
@@ -196,7 +324,11 @@ func (m *mapper) mapMap(mapVal reflect.Value, parentID nodeID, inlineable bool)
 	}
 
 	snode := createNode(id, mapType, "map")
+	snode.measure(mapVal)
+	valueType := getTypeName(mapVal.Type().Elem())
 
+	var keySummaries []string
+	var values []reflect.Value
 	for index, mapKey := range mapVal.MapKeys() { // []Value
 
 		if index > Options().MaxMapEntries {
@@ -204,9 +336,20 @@ func (m *mapper) mapMap(mapVal reflect.Value, parentID nodeID, inlineable bool)
 		}
 
 		_, keySummary := m.mapValue(mapKey, id, true)
+		snode.extra += inlineSize(mapKey)
 
 		value := mapVal.MapIndex(mapKey)
-		m.unified(snode, value, value.Type(), keySummary, index)
+		if kind := value.Kind(); kind == reflect.Struct || kind == reflect.Array {
+			// map values are not addressable: use a copy to get a stable key (instead of a counter based one)
+			value = m.addressable(value)
+		}
+		keySummaries = append(keySummaries, keySummary)
+		values = append(values, value)
+	}
+
+	m.prefetch(id, values)
+	for index, value := range values {
+		m.unified(snode, value, valueType, "", keySummaries[index], index)
 	}
 
 	if showZeroFields || !isEmpty(mapVal) || m.isRoot(mapVal) {
@@ -222,11 +365,28 @@ const (
 	portTitle   = "name"
 )
 
+// port names of the first (most used) rows
+var structRefs, structOutgoings = portNames("f", 256), portNames("o", 256)
+
+func portNames(prefix string, count int) []string {
+	names := make([]string, count)
+	for index := range names {
+		names[index] = prefix + strconv.Itoa(index)
+	}
+	return names
+}
+
 func getStructRef(index int) string {
+	if index < len(structRefs) {
+		return structRefs[index]
+	}
 	return fmt.Sprintf("f%d", index)
 }
 
 func getStructOutgoing(index int) string {
+	if index < len(structOutgoings) {
+		return structOutgoings[index]
+	}
 	return fmt.Sprintf("o%d", index)
 }
 
@@ -248,8 +408,7 @@ func GetFunctionName(i interface{}) string {
 
 func (m *mapper) explore(val reflect.Value) {
 	uType := val.Type()
-	id := m.getNodeID(val)
-	key := getNodeKey(val)
+	id, key := m.nodeOf(val)
 
 	// val.IsNil()
 
@@ -308,8 +467,9 @@ func show(format string, args ...interface{}) {
 
 var (
 	replacements = map[string]string{
-		"<": "&lt;",
-		">": "&gt;",
+		"<":  "&lt;",
+		">":  "&gt;",
+		"\n": "<BR ALIGN=\"LEFT\"/>", // see Settings.WrapStrings
 		/*
 			"\"": "&quot;",
 			"&":  "&amp;",
@@ -327,6 +487,7 @@ var (
 		"\"": "&quot;",
 		"<":  "&lt;",
 		">":  "&gt;",
+		"\n": "&#10;",
 		// "&":  "&amp;",
 		"¢": "&cent;",
 		"©": "&copy;",
@@ -338,29 +499,59 @@ var (
 	}
 )
 
-func txt2title(txt string) string {
-	txt = strings.Trim(txt, "\"")
-	txt = strings.ReplaceAll(txt, "&", "&amp;")
+// single pass equivalents of the (sequential) replacements above, "&" goes first
+var (
+	htmlizer = newReplacer(replacements)
+	titler   = newReplacer(titleReplacements)
+)
 
-	for from, to := range titleReplacements {
-		txt = strings.ReplaceAll(txt, from, to)
+func newReplacer(from map[string]string) *strings.Replacer {
+	pairs := []string{"&", "&amp;"}
+	for key, value := range from {
+		pairs = append(pairs, key, value)
 	}
+	return strings.NewReplacer(pairs...)
+}
 
-	return txt
+func txt2title(txt string) string {
+	if len(txt) > 1 && txt[0] == '"' && txt[len(txt)-1] == '"' {
+		txt = txt[1 : len(txt)-1]
+	}
+	if plainText(txt, &titleSpecials) {
+		return txt
+	}
+	return titler.Replace(txt)
 }
 
 func htmlize(txt string) string {
-
-	if strings.Contains(txt, "adaptSimpleTransport") {
-		// runtime.Breakpoint()
+	if plainText(txt, &htmlSpecials) {
+		return txt
 	}
-	txt = strings.ReplaceAll(txt, "&", "&amp;")
+	return htmlizer.Replace(txt)
+}
 
-	for from, to := range replacements {
-		txt = strings.ReplaceAll(txt, from, to)
+// asciiSet is a set of ascii characters (a bit per character)
+type asciiSet [4]uint32
+
+func newASCIISet(chars string) (set asciiSet) {
+	for index := 0; index < len(chars); index++ {
+		c := chars[index]
+		set[c/32] |= 1 << (c % 32)
 	}
+	return set
+}
+
+// the characters replaced by htmlize and txt2title
+var htmlSpecials, titleSpecials = newASCIISet("<>&\n"), newASCIISet("\"<>&\n")
 
-	return txt
+// plainText reports whether the (ascii) text has none of the special characters
+func plainText(txt string, special *asciiSet) bool {
+	for index := 0; index < len(txt); index++ {
+		if c := txt[index]; c >= 0x80 || special[c/32]&(1<<(c%32)) != 0 {
+			return false
+		}
+	}
+	return true
 }
 
 var escaper = strings.NewReplacer(
@@ -2,12 +2,38 @@
 
 package memory
 
-type Config struct{}
+import (
+	"reflect"
+	"time"
+)
+
+type Config struct {
+	palette *palette
+
+	noMethodCalls bool            // never invoke String()/Error() of the mapped values
+	methodAllow   map[string]bool // if not empty: the only types String()/Error() can be invoked on
+	methodDeny    map[string]bool // types String()/Error() are never invoked on
+	methodBudget  time.Duration   // max time a single String()/Error()/resolver call is given
+
+	streaming bool // write the nodes as they are completed (see stream)
+	workers   int  // max number of goroutines mapping the elements of a collection (see Parallel)
+
+	sizes   bool // show the shallow/retained sizes of the nodes
+	heatMap bool // color the headers by the retained size of the nodes
+
+	format OutputFormat
+
+	renderers map[reflect.Type]CustomResolver // values (of the given types) rendered inline instead of being explored
+	receivers map[reflect.Type]reflect.Type   // the renderers calling methods: the receiver of the methods (see methodRenderer)
+}
 
 type Configurator func(*Config)
 
 func defaultConfig() *Config {
-	return &Config{}
+	return &Config{
+		palette:   defaultPalette(),
+		renderers: timeRenderers,
+	}
 }
 
 func New(configurators ...Configurator) *Config {
@@ -17,3 +43,152 @@ func New(configurators ...Configurator) *Config {
 	}
 	return config
 }
+
+func (c *Config) getPalette() *palette {
+	if c.palette == nil {
+		return defaultPalette()
+	}
+	return c.palette
+}
+
+// CustomColors adds (or overrides) the header colors, keyed by the node tooltip (e.g. "struct: ast.File")
+func CustomColors(colors map[string]string) Configurator {
+	return func(c *Config) {
+		c.palette = c.getPalette().clone()
+		for name, color := range colors {
+			c.palette.colors[name] = correctColor(color)
+		}
+	}
+}
+
+// DisableMethodCalls prevents invocation of String()/Error() methods of the mapped values
+func DisableMethodCalls() Configurator {
+	return func(c *Config) {
+		c.noMethodCalls = true
+	}
+}
+
+// AllowMethodsOn limits invocation of String()/Error() methods to the listed types (e.g. "*url.URL", "time.Month")
+func AllowMethodsOn(types ...string) Configurator {
+	return func(c *Config) {
+		c.methodAllow = addTypes(c.methodAllow, types)
+	}
+}
+
+// DenyMethodsOn prevents invocation of String()/Error() methods of the listed types
+func DenyMethodsOn(types ...string) Configurator {
+	return func(c *Config) {
+		c.methodDeny = addTypes(c.methodDeny, types)
+	}
+}
+
+// MethodTimeBudget limits the time a single String()/Error()/resolver call is given.
+// note: a call exceeding the budget is abandoned (but cannot be stopped)
+func MethodTimeBudget(budget time.Duration) Configurator {
+	return func(c *Config) {
+		c.methodBudget = budget
+	}
+}
+
+func addTypes(to map[string]bool, types []string) map[string]bool {
+	result := make(map[string]bool, len(to)+len(types))
+	for name := range to {
+		result[name] = true
+	}
+	for _, name := range types {
+		result[name] = true
+	}
+	return result
+}
+
+// Streaming makes Map write the nodes as soon as they are completed instead of keeping them until the end
+// (at the cost of skipping the optimizations that require the knowledge of the whole graph).
+// note: the memory use still grows with the number of the nodes (their ids and summaries are kept), if not with their contents;
+// with LockedBy/Within the output is kept in memory until the lock is released
+func Streaming() Configurator {
+	return func(c *Config) {
+		c.streaming = true
+	}
+}
+
+// Parallel lets Map walk the elements of slices/arrays/maps on up to the given number of goroutines.
+// the results are merged in the order of the elements, so the output does not depend on the scheduling
+// (note: nodes shared by several elements might get walked more than once, only one copy is kept).
+// the resolvers, the renderers and the String()/Error() methods of the mapped values are called from several
+// goroutines at once, so they must be safe for concurrent use
+func Parallel(workers int) Configurator {
+	return func(c *Config) {
+		c.workers = workers
+	}
+}
+
+// Sizes shows the shallow size (the value itself plus the backing arrays of its strings, slices and maps)
+// and the retained size (everything that would be freed along with it) of every node.
+// note: not available in the streaming mode
+func Sizes() Configurator {
+	return func(c *Config) {
+		c.sizes = true
+	}
+}
+
+// HeatMap colors the node headers on a gradient from the smallest to the largest retained size
+func HeatMap() Configurator {
+	return func(c *Config) {
+		c.heatMap = true
+	}
+}
+
+// OutputFormat selects what Map writes
+type OutputFormat int
+
+const (
+	FormatGraph      OutputFormat = iota // graphviz digraph of the mapped values (default)
+	FormatDominators                     // graphviz digraph of the dominator tree, the largest (retained size) nodes first
+	FormatPprof                          // gzipped pprof profile (see "go tool pprof") of the retained memory
+)
+
+// Format selects the output of Map.
+// note: the formats other than FormatGraph need the whole graph, Streaming is ignored for them
+func Format(format OutputFormat) Configurator {
+	return func(c *Config) {
+		c.format = format
+	}
+}
+
+// FileExtension returns the extension of the files the output of Map is best saved in
+func (c *Config) FileExtension() string {
+	if c.format == FormatPprof {
+		return ".pb.gz"
+	}
+	return ".dot"
+}
+
+// DisableTimeRenderers shows time.Time, time.Duration, *time.Location, ... by their internals
+// (instead of e.g. "2024-05-01T10:00:00+02:00 CEST", "1.5s", "Europe/Berlin")
+func DisableTimeRenderers() Configurator {
+	return func(c *Config) {
+		c.renderers = c.withRenderers(timeRenderers, false)
+	}
+}
+
+// StandardRenderers shows the commonly used values of the standard library (net.IP, netip.Addr, *url.URL, *big.Int,
+// sql.NullString, *regexp.Regexp, reflect.Type, *os.File, ...) as one line summaries instead of their internals.
+// the values rendered by their methods are explored instead when the methods cannot be called (see DisableMethodCalls)
+func StandardRenderers() Configurator {
+	return func(c *Config) {
+		renderers := c.withRenderers(fileRenderers, true)
+		receivers := make(map[reflect.Type]reflect.Type, len(c.receivers)+len(standardRenderers))
+		for typ, receiver := range c.receivers {
+			receivers[typ] = receiver
+		}
+		for typ, method := range standardRenderers {
+			renderers[typ] = method.render
+			receivers[typ] = method.receiver
+		}
+		for _, typ := range nullableTypes {
+			renderers[typ] = c.nullable
+		}
+		c.renderers = renderers
+		c.receivers = receivers
+	}
+}
@@ -0,0 +1,177 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"io"
+	"sort"
+)
+
+// dominatorTree describes the captured graph: idom is the immediate dominator of each (reachable) node,
+// order lists the nodes in reverse postorder (every node preceded by its immediate dominator)
+type dominatorTree struct {
+	root  nodeID // virtual: dominates all the roots
+	idom  map[nodeID]nodeID
+	order []nodeID
+}
+
+// dominators computes the dominator tree of the graph (Cooper, Harvey, Kennedy: "A Simple, Fast Dominance Algorithm")
+func (m *mapper) dominators() dominatorTree {
+	const root = nodeID(-1)
+
+	successors := map[nodeID][]nodeID{}
+	for _, conn := range m.connections {
+		successors[conn.fromNode] = append(successors[conn.fromNode], conn.toNode)
+	}
+	for _, entry := range m.roots {
+		if entry.id != 0 {
+			successors[root] = append(successors[root], entry.id)
+		}
+	}
+	// nodes not reachable from any of the roots (shouldn't happen) hang off the virtual root as well
+	reachable := map[nodeID]bool{root: true}
+	var mark func(nodeID)
+	mark = func(id nodeID) {
+		for pending := []nodeID{id}; len(pending) > 0; {
+			current := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			for _, next := range successors[current] {
+				if !reachable[next] {
+					reachable[next] = true
+					pending = append(pending, next)
+				}
+			}
+		}
+	}
+	mark(root)
+	for _, node := range m.nodes {
+		if !reachable[node.id] {
+			successors[root] = append(successors[root], node.id)
+			reachable[node.id] = true
+			mark(node.id)
+		}
+	}
+
+	// postorder numbering (iterative dfs)
+	number := map[nodeID]int{}
+	var postorder []nodeID
+	type frame struct {
+		id   nodeID
+		next int
+	}
+	visited := map[nodeID]bool{root: true}
+	for stack := []frame{{root, 0}}; len(stack) > 0; {
+		top := &stack[len(stack)-1]
+		if next := successors[top.id]; top.next < len(next) {
+			child := next[top.next]
+			top.next++
+			if !visited[child] {
+				visited[child] = true
+				stack = append(stack, frame{child, 0})
+			}
+			continue
+		}
+		number[top.id] = len(postorder)
+		postorder = append(postorder, top.id)
+		stack = stack[:len(stack)-1]
+	}
+
+	predecessors := map[nodeID][]nodeID{}
+	for from, next := range successors {
+		for _, to := range next {
+			predecessors[to] = append(predecessors[to], from)
+		}
+	}
+
+	idom := map[nodeID]nodeID{root: root}
+	intersect := func(a, b nodeID) nodeID {
+		for a != b {
+			for number[a] < number[b] {
+				a = idom[a]
+			}
+			for number[b] < number[a] {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for index := len(postorder) - 2; index >= 0; index-- { // reverse postorder, skipping the root
+			id := postorder[index]
+			candidate, found := nodeID(0), false
+			for _, from := range predecessors[id] {
+				if _, processed := idom[from]; !processed {
+					continue
+				}
+				if !found {
+					candidate, found = from, true
+				} else {
+					candidate = intersect(from, candidate)
+				}
+			}
+			if found && idom[id] != candidate {
+				idom[id] = candidate
+				changed = true
+			}
+		}
+	}
+
+	tree := dominatorTree{root: root, idom: idom}
+	for index := len(postorder) - 2; index >= 0; index-- {
+		tree.order = append(tree.order, postorder[index])
+	}
+	return tree
+}
+
+// children returns the nodes immediately dominated by each node, the largest (retained) first
+func (tree dominatorTree) children(nodes map[nodeID]*cnode) map[nodeID][]nodeID {
+	children := map[nodeID][]nodeID{}
+	for _, id := range tree.order {
+		if _, found := nodes[id]; found {
+			parent := tree.idom[id]
+			children[parent] = append(children[parent], id)
+		}
+	}
+	for _, list := range children {
+		sort.SliceStable(list, func(i, j int) bool { return nodes[list[i]].retained > nodes[list[j]].retained })
+	}
+	return children
+}
+
+// writeDominators draws the dominator tree (instead of the graph itself): every node is connected
+// to the nodes it dominates (i.e. the nodes that would be freed along with it), the largest first
+func (m *mapper) writeDominators(w io.Writer) {
+	nodes, tree := m.measureSizes()
+	children := tree.children(nodes)
+	p := m.config.getPalette()
+
+	tableProlog(w, m.comment)
+	io.WriteString(w, "\tordering=out;\n")
+
+	var conns []connection
+	for pending := append([]nodeID(nil), children[tree.root]...); len(pending) > 0; {
+		id := pending[0]
+		pending = pending[1:]
+
+		nodes[id].write(w, p)
+		for _, child := range children[id] {
+			conns = append(conns, connection{
+				fromNode: id,
+				fromPort: portTitle,
+				toNode:   child,
+				toPort:   portTitle,
+				tooltip:  "retains " + byteSize(nodes[child].retained),
+				style:    connDefault,
+			})
+		}
+		pending = append(children[id], pending...)
+	}
+
+	io.WriteString(w, "\n\t/* ------ connections ------ */\n")
+	for _, conn := range conns {
+		conn.write(w, p)
+	}
+	tableEpilog(w, p, m.properties)
+}
@@ -0,0 +1,143 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"reflect"
+)
+
+var errorType = reflect.TypeFor[error]()
+
+// mapError renders the (addressable) value holding the error (errVal, the value itself or the pointer to it)
+// as its fields (if a struct) followed by its message, its concrete type and the errors it wraps (see errors.Unwrap
+// and errors.Join). the methods are invoked the same way String() is (see stringResolver); false if they cannot be,
+// the value is then rendered as any other
+func (m *mapper) mapError(id nodeID, value reflect.Value, errVal reflect.Value, name, tooltip string) bool {
+	if !errVal.CanInterface() || !m.canCallMethodsOf(errVal.Type()) {
+		return false
+	}
+	failure := errVal.Interface().(error)
+
+	snode := createNode(id, name, tooltip)
+	snode.measure(value)
+
+	first := 0 // the index of the first error row
+	if value.Kind() == reflect.Struct {
+		plan := m.planOf(value.Type())
+		snode.reserve(len(plan.fields) + 3)
+		for index, field := range plan.fields {
+			m.unified(snode, value.Field(index), field.typeName, field.path, field.name, index)
+		}
+		first = len(plan.fields)
+	}
+
+	message, kind := m.errorMessage(failure)
+	snode.addFieldInlined(getStructRef(first), "error", message, kind)
+	snode.addFieldInlined(getStructRef(first+1), "type", errVal.Type().String(), Type)
+
+	wrapped, err := m.unwrap(failure)
+	if err != nil {
+		snode.addFieldInlined(getStructRef(first+2), "unwraps", err.Error(), Failure)
+	}
+	for index, inner := range wrapped {
+		row := first + index + 2
+		name := "unwraps"
+		if len(wrapped) > 1 {
+			name += "[" + str(index) + "]"
+		}
+		if inner == nil {
+			snode.addFieldInlined(getStructRef(row), name, "nil", Blank)
+			continue
+		}
+
+		// mapped as held (by the interface): see mapDynamicError
+		innerID, _ := m.mapValue(reflect.ValueOf(&wrapped[index]).Elem(), id, false)
+		if m.stopped != nil {
+			return true
+		}
+		outgoing := getStructOutgoing(row)
+		snode.addCells(cell{port: getStructRef(row), name: name, kind: Key}, cell{port: outgoing, name: reflect.TypeOf(inner).String(), kind: Type})
+		m.addConnection(id, outgoing, innerID, name, connDefault)
+	}
+
+	m.addNode(snode)
+	return true
+}
+
+// errorValue returns the (addressable) struct itself if it implements error, the pointer to it otherwise
+func errorValue(structVal reflect.Value) reflect.Value {
+	if structVal.Type().Implements(errorType) {
+		return structVal
+	}
+	return structVal.Addr()
+}
+
+// mapDynamicError maps the error held by an interface (of any kind, e.g. type code int) as mapError does,
+// showing its dynamic type; false if it is to be mapped as any other value (e.g. already mapped)
+func (m *mapper) mapDynamicError(failure reflect.Value) (nodeID, string, bool) {
+	value := failure
+	if failure.Kind() == reflect.Pointer {
+		if failure.IsNil() {
+			return 0, "", false
+		}
+		value = failure.Elem()
+	} else {
+		// the value stored in an interface is not addressable
+		value = m.addressable(failure)
+	}
+	if !value.CanAddr() || !failure.CanInterface() || !m.canCallMethodsOf(failure.Type()) {
+		return 0, "", false
+	}
+
+	key := m.getNodeKey(value)
+	if _, known := m.nodeSummaries[key]; known {
+		return 0, "", false
+	}
+	if _, _, found := m.inherited(key); found {
+		return 0, "", false
+	}
+
+	summary := escapeString(failure.Type().String())
+	name, tooltip := summary, value.Kind().String()
+	if value.Kind() == reflect.Struct {
+		plan := m.planOf(value.Type())
+		name, tooltip, summary = plan.name, plan.tooltip, plan.summary
+	}
+
+	id := m.getNodeID(value)
+	m.nodeSummaries[key] = summary
+	m.mapError(id, value, failure, name, tooltip)
+	return id, summary, true
+}
+
+// errorMessage returns the result of Error() (or the reason it failed)
+func (m *mapper) errorMessage(failure error) (string, CellType) {
+	message, _, err := m.invoke(func() (string, bool) {
+		return failure.Error(), true
+	})
+	if err != nil {
+		return err.Error(), Failure
+	}
+	return message, Value
+}
+
+// unwrap returns the error(s) wrapped by the supplied one: Unwrap() error or Unwrap() []error
+func (m *mapper) unwrap(failure error) ([]error, error) {
+	// the call might outlive invoke (see MethodTimeBudget): the result is handed over, not shared
+	result := make(chan []error, 1)
+	_, _, err := m.invoke(func() (string, bool) {
+		switch typed := failure.(type) {
+		case interface{ Unwrap() error }:
+			result <- []error{typed.Unwrap()}
+		case interface{ Unwrap() []error }:
+			result <- typed.Unwrap()
+		default:
+			result <- nil
+		}
+		return "", true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return <-result, nil
+}
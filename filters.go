@@ -4,9 +4,13 @@ package memory
 
 import (
 	"fmt"
+	"math/bits"
 	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type ignoreResponse int
@@ -16,6 +20,8 @@ const (
 	ignoreCompletely ignoreResponse = 1
 	ignoreValue      ignoreResponse = 2
 
+	substituteFlags = "(flags)" // the entry marking the substitute table of bit flags
+
 	showHexForLargeInts = true
 	showTypeForInts     = true
 	showStructNilFields = true
@@ -45,13 +51,17 @@ func skipField(kind, collection, field string) ignoreResponse {
 	return doNotSkip
 }
 
-func (m *mapper) interpretValueType(val, typ string, value reflect.Value) (string, CellType, bool) {
+// interpretValueType renders the (inlined) value; path is the "struct:Type.field" of the struct fields (empty otherwise)
+func (m *mapper) interpretValueType(val, typ, path string, value reflect.Value) (string, CellType, bool) {
 
 	isNil := false
 	if len(Options().Substitute) != 0 {
-		if replace, found := Options().Substitute[typ]; found && len(replace) != 0 {
-			if change, exists := replace[val]; exists {
-				return change, Default, isNil
+		for _, key := range [...]string{path, typ} {
+			if replace, found := Options().Substitute[key]; found && len(replace) != 0 {
+				if change, exists := substitute(replace, val, value); exists {
+					return change, Default, isNil
+				}
+				break
 			}
 		}
 	}
@@ -67,7 +77,7 @@ func (m *mapper) interpretValueType(val, typ string, value reflect.Value) (strin
 		switch value.Kind() {
 		case reflect.String:
 			return "\"\"", Blank, isNil
-		case reflect.Pointer, reflect.Interface:
+		case reflect.Pointer, reflect.Interface, reflect.UnsafePointer:
 			return "nil", Blank, isNil // todo: pull the actual string from the mapper
 		case reflect.Bool:
 			return "false", Blank, isNil
@@ -80,19 +90,23 @@ func (m *mapper) interpretValueType(val, typ string, value reflect.Value) (strin
 		return "nil", Blank, isNil // todo: pull the actual string from the mapper
 	}
 
+	if value.Kind() == reflect.String && !utf8.ValidString(value.String()) {
+		return val, Special, isNil
+	}
+
 	if typ == "string" {
 		return val, Default, isNil
 	}
 
 	if optionAllowExternalResolver {
-		if txt, can := m.resolve(value); can {
-			return txt, ExternalResolver, isNil
+		if txt, cell, can := m.resolve(value); can {
+			return txt, cell, isNil
 		}
 	}
 
 	if optionAllowStringResolver {
-		if txt, can := stringResolver(value); can {
-			return txt, StringResolver, isNil
+		if txt, cell, can := m.stringResolver(value); can {
+			return txt, cell, isNil
 		}
 	}
 
@@ -105,32 +119,123 @@ func (m *mapper) interpretValueType(val, typ string, value reflect.Value) (strin
 	if showTypeForInts {
 		val += " (" + typ + ")"
 	}
+	if isSpecialNumber(value) {
+		return val, Special, isNil
+	}
 	return val, Default, isNil
 }
 
-func (m *mapper) resolve(value reflect.Value) (string, bool) {
+// substitute looks the value up in the table; the values of the flag tables (see substituteFlags)
+// without an entry of their own are decomposed into the named bits (and the leftover bits, in hex)
+func substitute(table map[string]string, val string, value reflect.Value) (string, bool) {
+	if change, exists := table[val]; exists {
+		return change, true
+	}
+	if _, flags := table[substituteFlags]; !flags {
+		return "", false
+	}
+
+	var number uint64
+	if value.CanUint() {
+		number = value.Uint()
+	} else if value.CanInt() {
+		number = uint64(value.Int())
+	} else {
+		return "", false
+	}
+	width := ^uint64(0) // the bits of the type (the negative values/keys are sign extended)
+	if size := value.Type().Bits(); size < 64 {
+		width = 1<<size - 1
+	}
+	return decomposeFlags(table, number&width, width)
+}
+
+func decomposeFlags(table map[string]string, value, width uint64) (string, bool) {
+	type flag struct {
+		mask uint64
+		name string
+	}
+	flags := make([]flag, 0, len(table))
+	for key, name := range table {
+		mask, err := strconv.ParseUint(key, 0, 64)
+		if err != nil {
+			signed, err := strconv.ParseInt(key, 0, 64)
+			if err != nil {
+				continue
+			}
+			mask = uint64(signed)
+		}
+		if mask &= width; mask != 0 {
+			flags = append(flags, flag{mask, name})
+		}
+	}
+	// the named combinations (masks) take precedence over the single bits they consist of
+	sort.Slice(flags, func(i, j int) bool {
+		if a, b := bits.OnesCount64(flags[i].mask), bits.OnesCount64(flags[j].mask); a != b {
+			return a > b
+		}
+		return flags[i].mask < flags[j].mask
+	})
+
+	var used []flag
+	rest := value
+	for _, f := range flags {
+		if rest&f.mask == f.mask {
+			used = append(used, f)
+			rest &^= f.mask
+		}
+	}
+	if len(used) == 0 {
+		return "", false
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].mask < used[j].mask })
+
+	names := make([]string, 0, len(used)+1)
+	for _, f := range used {
+		names = append(names, f.name)
+	}
+	if rest != 0 {
+		names = append(names, "0x"+strconv.FormatUint(rest, 16))
+	}
+	return strings.Join(names, "|"), true
+}
+
+func (m *mapper) resolve(value reflect.Value) (string, CellType, bool) {
 	for _, resolver := range m.resolvers {
-		if txt, yes := resolver(value); yes {
-			return txt, true
+		txt, yes, err := m.invoke(func() (string, bool) {
+			return resolver(value)
+		})
+		if err != nil {
+			return err.Error(), Failure, true
+		}
+		if yes {
+			return txt, ExternalResolver, true
 		}
 	}
 
-	return "", false
+	return "", Default, false
 }
 
 func canUseHex(value reflect.Value) (string, bool) {
+	// "%v (0x%x)"
+	var v uint64
+	text := make([]byte, 0, 48)
 	if value.CanUint() {
-		v := value.Uint()
-		if v > 16 {
-			return fmt.Sprintf("%v (0x%x)", v, v), true
+		if v = value.Uint(); v <= 16 {
+			return "", false
 		}
+		text = strconv.AppendUint(text, v, 10)
 	} else if value.CanInt() {
-		v := value.Int()
-		if v > 16 {
-			return fmt.Sprintf("%v (0x%x)", v, v), true
+		i := value.Int()
+		if i <= 16 {
+			return "", false
 		}
+		v, text = uint64(i), strconv.AppendInt(text, i, 10)
+	} else {
+		return "", false
 	}
-	return "", false
+	text = strconv.AppendUint(append(text, " (0x"...), v, 16)
+	return string(append(text, ')')), true
 }
 
 func warning0(format string, args ...interface{}) {
@@ -0,0 +1,97 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var resolvedMethods = []string{"String", "Error"}
+
+// stringResolver renders the value using its String() (or Error()) method, if allowed by the config
+func (m *mapper) stringResolver(value reflect.Value) (string, CellType, bool) {
+	if !value.IsValid() || !m.canCallMethodsOf(value.Type()) {
+		return "", Default, false
+	}
+
+	for _, index := range m.methodsOf(value.Type()) {
+		if index < 0 {
+			continue
+		}
+		method := value.Method(index)
+
+		txt, _, err := m.invoke(func() (string, bool) {
+			back := method.Call([]reflect.Value{})
+			return back[0].String(), true
+		})
+		if err != nil {
+			return err.Error(), Failure, true
+		}
+		return txt, StringResolver, true
+	}
+
+	return "", Default, false
+}
+
+// canCallMethodsOf reports whether String()/Error() of the type can be called now: never while in the critical section
+// of the snapshot mode (see Within), as the methods might need the very lock being held
+func (m *mapper) canCallMethodsOf(typ reflect.Type) bool {
+	return !m.snapshot && m.config.canCallMethodsOf(typ)
+}
+
+func (c *Config) canCallMethodsOf(typ reflect.Type) bool {
+	if c.noMethodCalls {
+		return false
+	}
+
+	name := typ.String()
+	if c.methodDeny[name] {
+		return false
+	}
+	if len(c.methodAllow) > 0 {
+		return c.methodAllow[name]
+	}
+	return true
+}
+
+type invocation struct {
+	txt string
+	ok  bool
+	err error
+}
+
+// invoke runs the supplied (user) code: a panic is reported as an error, as is exceeding the time budget
+func (m *mapper) invoke(call func() (string, bool)) (string, bool, error) {
+	guarded := func() (result invocation) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = invocation{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		txt, ok := call()
+		return invocation{txt: txt, ok: ok}
+	}
+
+	budget := m.config.methodBudget
+	if budget <= 0 {
+		result := guarded()
+		return result.txt, result.ok, result.err
+	}
+
+	done := make(chan invocation, 1)
+	go func() {
+		done <- guarded()
+	}()
+
+	timer := time.NewTimer(budget)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		return result.txt, result.ok, result.err
+	case <-timer.C:
+		return "", false, fmt.Errorf("timeout: no result after %v", budget)
+	}
+}
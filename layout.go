@@ -0,0 +1,162 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const cacheLineSize = 64
+
+// MapLayout draws the memory layout of the supplied struct types (reflect.Type) or values of such types (using the default config)
+func MapLayout(w io.Writer, is ...interface{}) error {
+	return defaultConfig().MapLayout(w, is...)
+}
+
+// MapLayout draws the memory layout of the supplied struct types (reflect.Type) or values of such types:
+// the offset, size and alignment of every field, the padding, the cache line boundaries
+// and the order of the fields minimizing the padding
+func (c *Config) MapLayout(w io.Writer, is ...interface{}) error {
+	w, done, err := c.output(w)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	req := parseArguments(is)
+	m := c.newMapper(w, req)
+	for _, root := range req.roots {
+		typ := rootType(root.Value)
+		if typ == nil || typ.Kind() != reflect.Struct {
+			warning("not a struct: %v", typ)
+			continue
+		}
+		m.roots = append(m.roots, rootEntry{root.Name, m.layout(typ)})
+	}
+
+	m.write(w)
+	return nil
+}
+
+// typeID returns the id of the node representing the type; false if the type is already known
+func (m *mapper) typeID(typ reflect.Type) (nodeID, bool) {
+	key := nodeKey{typ: typ, kind: typ.Kind(), indirect: true}
+	if id, found := m.nodeIDs[key]; found {
+		return id, false
+	}
+	id := nodeID(len(m.nodeIDs))
+	m.nodeIDs[key] = id
+	return id, true
+}
+
+// layout adds the node describing the layout of the struct type (and of the struct types of its fields)
+func (m *mapper) layout(typ reflect.Type) nodeID {
+	id, fresh := m.typeID(typ)
+	if !fresh {
+		return id
+	}
+
+	snode := createNode(id, m.getStructTypeName(typ), "layout: "+typ.String())
+	row := func(port string, offset uintptr, kind CellType, texts ...string) {
+		cells := []cell{{port: port, name: strconv.FormatUint(uint64(offset), 10), kind: Key}}
+		for _, text := range texts {
+			cells = append(cells, cell{name: text, kind: kind})
+		}
+		snode.addCells(cells...)
+	}
+
+	var end uintptr
+	line := uintptr(cacheLineSize)
+	boundaries := func(upTo uintptr) {
+		for ; line <= upTo && line < typ.Size(); line += cacheLineSize {
+			row("", line, CacheLine, "cache line "+strconv.Itoa(int(line/cacheLineSize)), "", "")
+		}
+	}
+	padding := func(upTo uintptr) {
+		boundaries(end)
+		if upTo > end {
+			row("", end, Padding, "padding", byteSize(upTo-end), "")
+		}
+		boundaries(upTo)
+	}
+
+	for index := 0; index < typ.NumField(); index++ {
+		field := typ.Field(index)
+		padding(field.Offset)
+
+		size := field.Type.Size()
+		details := byteSize(size) + " / align " + strconv.Itoa(field.Type.Align())
+		if size > 0 && field.Offset/cacheLineSize != (field.Offset+size-1)/cacheLineSize {
+			details += " (crosses a cache line)"
+		}
+
+		fieldType := getTypeName(field.Type)
+		snode.addCells(
+			cell{port: getStructRef(index), name: strconv.FormatUint(uint64(field.Offset), 10), kind: Key},
+			cell{name: field.Name, kind: Value},
+			cell{port: getStructOutgoing(index), name: fieldType, kind: Type},
+			cell{name: details, kind: Info})
+
+		if field.Type.Kind() == reflect.Struct && field.Type.NumField() > 0 {
+			m.addConnection(id, getStructOutgoing(index), m.layout(field.Type), field.Name, connInner)
+		}
+		end = field.Offset + size
+	}
+	padding(typ.Size())
+
+	order := optimalOrder(typ)
+	optimal := layoutSize(order, typ.Align())
+	snode.addField("", "size: "+byteSize(typ.Size())+", optimal: "+byteSize(optimal)+", align: "+strconv.Itoa(typ.Align()), Footer)
+	if optimal < typ.Size() {
+		names := make([]string, len(order))
+		for index, field := range order {
+			names[index] = field.Name
+		}
+		snode.addField("", "suggested order: "+strings.Join(names, ", "), Footer)
+	}
+
+	m.addNode(snode)
+	return id
+}
+
+// optimalOrder returns the fields of the struct in the order minimizing the padding:
+// zero sized first (a trailing one would get padded), then by decreasing alignment and size
+func optimalOrder(typ reflect.Type) []reflect.StructField {
+	fields := make([]reflect.StructField, typ.NumField())
+	for index := range fields {
+		fields[index] = typ.Field(index)
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i].Type, fields[j].Type
+		if (a.Size() == 0) != (b.Size() == 0) {
+			return a.Size() == 0
+		}
+		if a.Align() != b.Align() {
+			return a.Align() > b.Align()
+		}
+		return a.Size() > b.Size()
+	})
+	return fields
+}
+
+// layoutSize returns the size of a struct with the fields in the given order
+func layoutSize(fields []reflect.StructField, align int) uintptr {
+	var offset uintptr
+	for _, field := range fields {
+		offset = alignUp(offset, uintptr(field.Type.Align())) + field.Type.Size()
+	}
+	if count := len(fields); count > 0 && fields[count-1].Type.Size() == 0 && offset > 0 {
+		// a pointer to the trailing zero sized field must not point past the struct
+		offset++
+	}
+	return alignUp(offset, uintptr(align))
+}
+
+func alignUp(offset, align uintptr) uintptr {
+	return (offset + align - 1) / align * align
+}
@@ -3,12 +3,14 @@
 package memory
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
 	"strings"
 	"sync/atomic"
+	"time"
 )
 
 //var spewer = &spew.ConfigState{
@@ -21,7 +23,13 @@ import (
 //}
 
 type (
-	nodeKey         string
+	nodeKey struct {
+		typ      reflect.Type // type of the (addressable) value
+		addr     uintptr      // address of the value (or the value of the pointer, if indirect)
+		seq      int          // for values without an address: sequential number
+		kind     reflect.Kind
+		indirect bool // addr is the value of a pointer/map/slice/... (the value itself is not addressable)
+	}
 	nodeID          int
 	connectionStyle int
 
@@ -38,6 +46,17 @@ type (
 
 	CustomResolver    = func(value reflect.Value) (string, bool)
 	CustomInformation = func() map[string]string
+
+	// NamedRoot is a value to be mapped (and drawn in its own cluster) under the given name
+	NamedRoot struct {
+		Name  string
+		Value interface{}
+	}
+
+	rootEntry struct {
+		name string
+		id   nodeID
+	}
 )
 
 const (
@@ -47,8 +66,10 @@ const (
 	connInner
 )
 
+const reserved = "(reserved)" // summary of the nodes being mapped
+
 var (
-	nilKey         nodeKey = "nil0"
+	nilKey         nodeKey // zero value: invalid kind
 	tmpFileCounter int32
 )
 
@@ -68,6 +89,35 @@ type mapper struct {
 	currentRoot  reflect.Value
 
 	resolvers []CustomResolver
+	roots     []rootEntry
+	copies    map[nodeID]reflect.Value // nodes created from (addressable) copies of unaddressable values (kept alive: their addresses are keys)
+
+	config     *Config
+	keyCounter int               // for values that aren't addressable
+	anonymous  map[string]string // names given to anonymous structs
+
+	ctx     context.Context // optional: stops the traversal once done
+	stopped error           // reason the traversal was stopped (if it was)
+
+	stream *stream // streaming mode: nodes are written as soon as completed
+
+	plans   map[reflect.Type]*structPlan
+	methods map[reflect.Type][]int // see methodsOf
+
+	parent       *mapper                 // set for the sub-mappers of the parallel traversal (see prefetch)
+	placeholders map[string]reflect.Type // sub-mappers: anonymous structs yet to be named (by the parent)
+
+	embedded map[nodeID]bool // nodes stored within the memory of another node (struct fields, slice elements, ...)
+
+	snapshot bool // collecting within the critical section (see Within): String()/Error() are not called
+
+	addresses map[uintptr][]nodeKey // the nodes by their addresses (see capturedAt)
+	unindexed []nodeKey             // the keys (with addresses) identified since addresses were last updated
+}
+
+// Root names the supplied value: Map draws it (and everything reachable only from it) in its own cluster
+func Root(name string, value interface{}) NamedRoot {
+	return NamedRoot{Name: name, Value: value}
 }
 
 // Map prints the given datastructure using the default config
@@ -75,37 +125,85 @@ func Map(w io.Writer, is ...interface{}) {
 	defaultConfig().Map(w, is...)
 }
 
-// Map prints out a Graphviz digraph of the given datastructure to the given io.Writer
+// Map prints out a Graphviz digraph of the given datastructure to the given io.Writer.
+// the arguments are either MapOption(s) (see Values, Comment, WithResolver, WithInfo) or
+// (legacy form) the values to be mapped intermixed with comment/resolvers/info providers
 func (c *Config) Map(w io.Writer, is ...interface{}) {
 
 	trace("==================[%v]==[%v]===\n", w, is) // todo: remove this mask of "later" bug
 
-	var comment string
-	lenis := len(is)
-	if lenis > 1 {
-		if txt, converts := is[lenis-1].(string); converts {
-			comment = strings.ReplaceAll(txt, "\"", "\\")
-			// is = is[:lenis-1]
-		}
+	c.mapRequest(w, parseArguments(is))
+}
+
+// MapContext is Map (using the default config) that can be interrupted via the supplied context
+func MapContext(ctx context.Context, w io.Writer, is ...interface{}) error {
+	return defaultConfig().MapContext(ctx, w, is...)
+}
+
+// MapContext is Map that stops the traversal once the context is done: whatever was collected
+// by then is rendered (marked as incomplete) and the context's error is returned
+func (c *Config) MapContext(ctx context.Context, w io.Writer, is ...interface{}) error {
+	req := parseArguments(is)
+	req.ctx = ctx
+	return c.mapRequest(w, req)
+}
+
+func (c *Config) mapRequest(w io.Writer, req *request) error {
+	w, done, err := c.output(w)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	m := c.newMapper(w, req)
+	if m.stream != nil {
+		defer m.stream.close()
 	}
 
-	comment = strings.ReplaceAll(comment, "\\", "/")
+	if req.critical != nil {
+		// snapshot mode: collect everything while in the critical section, render afterwards
+		var held time.Duration
+		req.critical(func() {
+			start := time.Now()
+			m.snapshot = true
+			defer func() {
+				held = time.Since(start)
+				m.snapshot = false
+			}()
+			m.traverse(req.roots)
+		})
+		m.addInfo("lock held", "%v", held)
+	} else {
+		m.traverse(req.roots)
+	}
 
-	if w == nil {
-		current := atomic.AddInt32(&tmpFileCounter, 1)
-		fileName := fmt.Sprintf("./memory-%v.dot", current)
-		f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
-		if err != nil {
-			warning("failed to create file (%s), due to: %v", fileName, err)
-			return
-		}
-		w = f
-		defer func() {
-			trace("closing file: %v", fileName)
-			f.Close()
-		}()
+	if m.stopped != nil {
+		m.addInfo("incomplete", "cancelled after %d nodes", m.nodeCount())
+	}
+	m.write(w)
+	return m.stopped
+}
+
+// output returns the writer to write into: the supplied one or (if nil) a new file in the current folder
+func (c *Config) output(w io.Writer) (io.Writer, func(), error) {
+	if w != nil {
+		return w, func() {}, nil
 	}
 
+	current := atomic.AddInt32(&tmpFileCounter, 1)
+	fileName := fmt.Sprintf("./memory-%v%s", current, c.FileExtension())
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		warning("failed to create file (%s), due to: %v", fileName, err)
+		return nil, nil, err
+	}
+	return f, func() {
+		trace("closing file: %v", fileName)
+		f.Close()
+	}, nil
+}
+
+func (c *Config) newMapper(w io.Writer, req *request) *mapper {
 	m := &mapper{
 		w,
 		map[nodeKey]nodeID{nilKey: 0},
@@ -114,74 +212,80 @@ func (c *Config) Map(w io.Writer, is ...interface{}) {
 		nil,
 		nil,
 		info{},
-		comment,
+		req.comment,
 		map[uintptr]reflect.Value{},
 		reflect.Value{},
+		req.resolvers,
+		nil,
+		map[nodeID]reflect.Value{},
+		c,
+		0,
+		map[string]string{},
+		req.ctx,
+		nil,
+		nil,
+		map[reflect.Type]*structPlan{},
+		map[reflect.Type][]int{},
+		nil,
+		nil,
+		map[nodeID]bool{},
+		false,
+		map[uintptr][]nodeKey{},
 		nil,
 	}
 
+	if c.streaming && c.format == FormatGraph {
+		m.stream = newStream(w, c.getPalette(), req.comment, req.critical != nil)
+	}
+
+	for _, inform := range req.info {
+		for key, value := range inform() {
+			m.addInfo(key, "%s", value)
+		}
+	}
+	return m
+}
+
+// traverse collects the nodes/connections reachable from the supplied roots
+func (m *mapper) traverse(roots []NamedRoot) {
 	var iVals []reflect.Value
-	for index, i := range is {
-		// fmt.Printf("type of %v: %T\n", index, i)
-		_ = index
-
-		if txt, ok := i.(string); ok {
-			// this is a comment - ignore it
-			if len(m.comment) == 0 {
-				m.comment = txt
-			}
-		} else if reolver, ok := i.(CustomResolver); ok {
-			m.add(reolver)
-		} else if inform, ok := i.(CustomInformation); ok {
-			for key, value := range inform() {
-				m.addInfo(key, value)
-			}
-		} else if inform, ok := i.(func() map[string]string); ok {
-			for key, value := range inform() {
-				m.addInfo(key, value)
-			}
+	for _, root := range roots {
+		iVal := reflect.ValueOf(root.Value)
+		if iVal.Kind() == reflect.Pointer || iVal.Kind() == reflect.Interface {
+			iVal = iVal.Elem()
 		} else {
-			iVal := reflect.ValueOf(i)
-			if !iVal.CanAddr() {
-				if iVal.Kind() != reflect.Pointer && iVal.Kind() != reflect.Interface {
-					fmt.Fprint(w, "error: cannot map unaddressable value")
-					return
-				}
-
-				iVal = iVal.Elem()
-			}
-			iVals = append(iVals, iVal)
+			// passed by value: map a copy of it
+			iVal = m.addressable(iVal)
 		}
+		iVals = append(iVals, iVal)
 	}
 
 	// fmt.Fprintln(w, "digraph structs {")
 	// fmt.Fprintln(w, "  node [shape=Mrecord];")
-	for _, iVal := range iVals {
+	for index, iVal := range iVals {
 		m.currentRoot = iVal
-		m.mapValue(iVal, 0, false)
+		id, _ := m.mapValue(iVal, 0, false)
+		m.roots = append(m.roots, rootEntry{roots[index].Name, id})
 	}
 	m.currentRoot = reflect.Value{}
 	// fmt.Fprintln(w, "}")
-	m.write(w)
 }
 
-// for values that aren't addressable keep an incrementing counter instead
-var keyCounter int
-
-func getNodeKey(val reflect.Value) nodeKey {
-	if val.CanAddr() {
-		// return nodeKey(fmt.Sprint(val.Kind()) + fmt.Sprint(val.UnsafeAddr()))
-		txt := fmt.Sprintf("%s:%x:%s", val.Kind(), val.UnsafeAddr(), val.Type().Name())
-		return nodeKey(txt)
+func (key nodeKey) String() string {
+	switch {
+	case key.seq != 0:
+		return fmt.Sprintf("%v=%s", key.seq, key.kind)
+	case key.indirect:
+		return fmt.Sprintf("%s@%x", key.kind, key.addr)
+	case key.typ != nil:
+		return fmt.Sprintf("%s:%x:%s", key.kind, key.addr, key.typ)
 	}
+	return "nil0"
+}
 
-	// reverse order of type and "address" to prevent (incredibly unlikely) collisions
-
-	if val.Kind() == reflect.Pointer {
-		if !val.IsNil() {
-			got := getNodeKey(val.Elem())
-			trace("pointer to: %v\n", got)
-		}
+func (m *mapper) getNodeKey(val reflect.Value) nodeKey {
+	if val.CanAddr() {
+		return nodeKey{kind: val.Kind(), addr: val.UnsafeAddr(), typ: val.Type()}
 	}
 
 	// *.Pointer returns v's value as a uintptr.
@@ -191,26 +295,56 @@ func getNodeKey(val reflect.Value) nodeKey {
 		if val.IsValid() && !val.IsZero() {
 			ptr := val.Pointer()
 			if ptr != 0 {
-				txt := fmt.Sprintf("%s@%x", val.Kind().String(), ptr)
-				return nodeKey(txt)
+				return nodeKey{kind: val.Kind(), addr: ptr, indirect: true}
 			}
 		}
 	}
 
-	keyCounter++
-	return nodeKey(fmt.Sprintf("%v=%s", keyCounter, val.Kind()))
+	// for values that aren't addressable keep an incrementing counter instead
+	m.keyCounter++
+	return nodeKey{kind: val.Kind(), seq: m.keyCounter}
 }
 
 func (m *mapper) getNodeID(iVal reflect.Value) nodeID {
+	id, _ := m.nodeOf(iVal)
+	return id
+}
+
+// nodeOf returns both the id and the key of the value's node (see getNodeID)
+func (m *mapper) nodeOf(iVal reflect.Value) (nodeID, nodeKey) {
 	// have to key on kind and address because a struct and its first element have the same UnsafeAddr()
-	key := getNodeKey(iVal)
+	key := m.getNodeKey(iVal)
 	if id, ok := m.nodeIDs[key]; !ok {
 		id = nodeID(len(m.nodeIDs))
-		m.nodeIDs[key] = id
-		return id
+		if m.parent != nil {
+			// local to the sub-mapper: replaced once merged into the parent
+			id = -id
+		}
+		m.identify(key, id)
+		return id, key
 	} else {
-		return id
+		return id, key
+	}
+}
+
+// identify records the id of the node of the key
+func (m *mapper) identify(key nodeKey, id nodeID) {
+	m.nodeIDs[key] = id
+	if key.addr != 0 {
+		m.unindexed = append(m.unindexed, key)
+	}
+}
+
+// addressable returns an addressable copy of the supplied (unaddressable) value
+func (m *mapper) addressable(val reflect.Value) reflect.Value {
+	if !val.IsValid() || val.CanAddr() || !val.CanInterface() {
+		return val
 	}
+
+	copied := reflect.New(val.Type()).Elem()
+	copied.Set(val)
+	m.copies[m.getNodeID(copied)] = copied
+	return copied
 }
 
 func (m *mapper) add(resolver CustomResolver) {
@@ -219,7 +353,12 @@ func (m *mapper) add(resolver CustomResolver) {
 
 func (m *mapper) newBasicNode(iVal reflect.Value, text string) nodeID {
 	id := m.getNodeID(iVal)
-	m.addNode(createNode(id, text, iVal.Kind().String()))
+	node := createNode(id, text, iVal.Kind().String())
+	node.measure(iVal)
+	if title := fullString(iVal, text); len(title) > 0 {
+		node.doc = title // (as for the fields, see unified)
+	}
+	m.addNode(node)
 	// fmt.Fprintf(m.writer, "  %d [label=\"<name> %s\"];\n", id, text)
 	return id
 }
@@ -277,18 +416,76 @@ func (m *mapper) known(iVal reflect.Value) bool {
 	return false
 }
 
+// cancelled reports whether the traversal has to stop
+func (m *mapper) cancelled() bool {
+	if m.stopped != nil {
+		return true
+	}
+	if m.ctx == nil {
+		return false
+	}
+
+	select {
+	case <-m.ctx.Done():
+		m.stopped = m.ctx.Err()
+		return true
+	default:
+		return false
+	}
+}
+
 func (m *mapper) mapValue(iVal reflect.Value, parentID nodeID, inlineable bool) (nodeID, string) {
-	if !iVal.IsValid() {
+	if !iVal.IsValid() || m.cancelled() {
 		// zero value => probably result of nil pointer
 		return m.nodeIDs[nilKey], m.nodeSummaries[nilKey]
 	}
 
-	key := getNodeKey(iVal)
-	describe("map.value", iVal)
+	if text, _, rendered := m.render(iVal); rendered {
+		if inlineable {
+			return 0, text
+		}
+		m.nodeSummaries[m.getNodeKey(iVal)] = text
+		return m.newBasicNode(iVal, text), text
+	}
+	return m.mapUnrendered(iVal, parentID, inlineable)
+}
+
+// mapUnrendered is mapValue of the (valid) value known to have no renderer (see unified)
+func (m *mapper) mapUnrendered(iVal reflect.Value, parentID nodeID, inlineable bool) (nodeID, string) {
+	if m.cancelled() {
+		return m.nodeIDs[nilKey], m.nodeSummaries[nilKey]
+	}
+
+	if inlineable && isScalar(iVal.Kind()) {
+		// (inlined) scalars cannot be shared nor refer to anything: no need to remember them
+		return m.mapKind(iVal, parentID, inlineable)
+	}
+
+	if iVal.Kind() == reflect.Interface && !iVal.IsNil() {
+		// the errors are recognized by their dynamic types, whatever their kinds
+		if dynamic := iVal.Elem(); dynamic.Type().Implements(errorType) {
+			if id, summary, mapped := m.mapDynamicError(dynamic); mapped {
+				return id, summary
+			}
+		}
+	}
+
+	if kind := iVal.Kind(); isIndirection(kind) {
+		if pointee := iVal.Elem(); !pointee.IsValid() || !pointee.IsZero() {
+			// nil, or represented by the node of the value (see mapPtrIface): nothing to remember about the pointer itself
+			if inner := pointee.Kind(); kind == reflect.Interface && (inner == reflect.Struct || inner == reflect.Array) {
+				pointee = m.addressable(pointee)
+			}
+			return m.mapValue(pointee, parentID, inlineable)
+		}
+	}
+
+	key := m.getNodeKey(iVal)
+	m.describe("map.value", iVal)
 
 	// m.known(iVal)
 
-	const reserved = "(reserved)"
+	fresh := false
 	if summary, ok := m.nodeSummaries[key]; ok {
 		// already seen this address so no need to map again
 		if summary != reserved {
@@ -296,17 +493,40 @@ func (m *mapper) mapValue(iVal reflect.Value, parentID nodeID, inlineable bool)
 		} else {
 			debug()
 		}
-	} else {
+	} else if id, summary, found := m.inherited(key); found {
+		// already mapped by the parent (of this sub-mapper)
+		return id, summary
+	} else if kind := iVal.Kind(); kind != reflect.Struct && kind != reflect.Slice && kind != reflect.Array {
 		// to deal with "references to itself" let's "reserve" the spot here
+		// (structs, slices and arrays set their summaries before mapping their content)
 		m.nodeSummaries[key] = reserved
+		fresh = true
+	}
 
-		defer func() {
-			if m.nodeSummaries[key] == reserved {
-				warning("node [%s] was not updated\n", key)
-			}
-		}()
+	id, summary := m.mapKind(iVal, parentID, inlineable)
+	if fresh && !isIndirection(iVal.Kind()) && m.nodeSummaries[key] == reserved {
+		// (the pointers/interfaces to a value are represented by the node of the value)
+		warning("node [%s] was not updated\n", key)
+	}
+	return id, summary
+}
+
+func isIndirection(kind reflect.Kind) bool {
+	return kind == reflect.Pointer || kind == reflect.Interface
+}
+
+func isScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return true
 	}
+	return false
+}
 
+func (m *mapper) mapKind(iVal reflect.Value, parentID nodeID, inlineable bool) (nodeID, string) {
 	switch iVal.Kind() {
 	// Indirections
 	case reflect.Ptr, reflect.Interface:
@@ -339,6 +559,13 @@ func (m *mapper) mapValue(iVal reflect.Value, parentID nodeID, inlineable bool)
 	case reflect.Uintptr:
 		return m.mapUint(iVal, inlineable)
 
+	case reflect.Float32, reflect.Float64:
+		return m.mapFloat(iVal, inlineable)
+	case reflect.Complex64, reflect.Complex128:
+		return m.mapComplex(iVal, inlineable)
+	case reflect.UnsafePointer:
+		return m.mapUnsafePointer(iVal, inlineable)
+
 	// If we've missed anything then just fmt.Sprint it
 	default:
 		report("unhandled: %v\n", iVal.Kind().String())
@@ -361,71 +588,43 @@ func (m *mapper) addInfo(key string, format string, args ...interface{}) {
 	m.properties.add(key, format, args...)
 }
 
-func (conn *connection) write(w io.Writer) {
-	out := func(format string, arg ...interface{}) {
-		fmt.Fprintf(w, format, arg...)
-	}
-
+func (conn *connection) write(w io.Writer, p *palette) {
 	// [weight=1, penwidth=3 color="#9ACEEB" tooltip="text"];
-	styles := []string{}
-	toPort := conn.toPort
-
-	add := func(what string, args ...interface{}) {
-		styles = append(styles, fmt.Sprintf(what, args...))
-	}
-	port := func(to string) {
-		toPort += ":" + to
-	}
-
-	for prop, value := range connectorProperties[conn.style] {
-		switch prop {
-		case "port":
-			port(value)
-		case "color":
-			add("%s=\"%s\"", prop, value)
-		default:
-			add("%s=%s", prop, value)
-		}
+	link := p.links[conn.style]
+
+	// \t%v:<%v>:e\t-> %v:%v%s;\n
+	io.WriteString(w, "\t")
+	conn.fromNode.writeName(w)
+	writeStrings(w, ":<", conn.fromPort, ">:e\t-> ")
+	conn.toNode.writeName(w)
+	writeStrings(w, ":", conn.toPort, link.port)
+
+	separator := " ["
+	for _, attribute := range link.attributes {
+		writeStrings(w, separator, attribute)
+		separator = " "
 	}
 
-	/*
-		switch conn.style {
-		case connDefault:
-			add("color=\"%s\"", "black")
-			port("w")
-		case connPointer:
-			add("color=\"%s\"", "red")
-			port("w") // "n"
-		case connArray:
-			add("color=\"%s\"", "blue")
-		case connInner:
-			add("color=\"%s\"", "green")
-			add("weight=3")
-			add("penwidth=3")
-			port("w")
-		}
-	*/
-
-	// style = Options().LinkPointer
-	// Options().LinkArray
-
 	if len(conn.tooltip) > 0 {
 		if tooltip := strings.Trim(conn.tooltip, " \t\"\r\n"); len(tooltip) > 0 {
-			add("tooltip=\"%s\"", tooltip)
+			writeStrings(w, separator, "tooltip=\"", tooltip, "\"")
+			separator = " "
 		}
 	}
 
 	if optionAllowMetadata {
-		add("id=\"%s;%s;\"", conn.fromNode.getName(), conn.toNode.getName())
+		writeStrings(w, separator, "id=\"")
+		conn.fromNode.writeName(w)
+		io.WriteString(w, ";")
+		conn.toNode.writeName(w)
+		io.WriteString(w, ";\"")
+		separator = " "
 	}
 
-	style := ""
-	if len(styles) > 0 {
-		style = " [" + strings.Join(styles, " ") + "]"
+	if separator == " " {
+		io.WriteString(w, "]")
 	}
-
-	out("\t%v:<%v>:e\t-> %v:%v%s;\n", conn.fromNode.getName(), conn.fromPort, conn.toNode.getName(), toPort, style)
-
+	io.WriteString(w, ";\n")
 }
 
 func kind2style(from reflect.Kind) connectionStyle {
@@ -455,14 +654,3 @@ func value2style(iVal reflect.Value) connectionStyle {
 	}
 	return connDefault
 }
-
-func stringResolver(value reflect.Value) (string, bool) {
-	// value.CanConvert()
-	if str := value.MethodByName("String"); str.IsValid() {
-		if back := str.Call([]reflect.Value{}); len(back) == 1 && back[0].IsValid() {
-			return back[0].String(), true
-		}
-	}
-
-	return "", false
-}
@@ -0,0 +1,102 @@
+// github.com/seamia/memory
+
+package memory_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/seamia/memory"
+)
+
+type (
+	benchNode struct {
+		ID          int
+		Name        string
+		Left, Right *benchNode
+	}
+
+	benchLink struct {
+		Value int
+		Next  *benchLink
+	}
+
+	benchEntry struct {
+		Key   string
+		Value float64
+		Tags  []string
+	}
+)
+
+// newBenchTree builds a (complete) binary tree of the given number of nodes
+func newBenchTree(count int) *benchNode {
+	nodes := make([]benchNode, count)
+	for index := range nodes {
+		nodes[index] = benchNode{ID: index, Name: fmt.Sprint("node-", index)}
+		if left := 2*index + 1; left < count {
+			nodes[index].Left = &nodes[left]
+		}
+		if right := 2*index + 2; right < count {
+			nodes[index].Right = &nodes[right]
+		}
+	}
+	return &nodes[0]
+}
+
+func newBenchChain(length int) *benchLink {
+	var head *benchLink
+	for index := 0; index < length; index++ {
+		head = &benchLink{Value: index, Next: head}
+	}
+	return head
+}
+
+// withLimits lifts the limits on the number of the slice elements/map entries shown for the duration of the benchmark
+func withLimits(b *testing.B, elements int) {
+	options := memory.Options()
+	slices, maps := options.MaxSliceLength, options.MaxMapEntries
+	options.MaxSliceLength, options.MaxMapEntries = elements, elements
+	b.Cleanup(func() {
+		options.MaxSliceLength, options.MaxMapEntries = slices, maps
+	})
+}
+
+func benchmarkMap(b *testing.B, config *memory.Config, value interface{}) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		config.Map(io.Discard, value)
+	}
+}
+
+func BenchmarkMapTree100k(b *testing.B) {
+	benchmarkMap(b, memory.New(), newBenchTree(100_000))
+}
+
+func BenchmarkMapPointerChain(b *testing.B) {
+	benchmarkMap(b, memory.New(), newBenchChain(20_000))
+}
+
+func BenchmarkMapLargeSlice(b *testing.B) {
+	entries := make([]benchEntry, 20_000)
+	for index := range entries {
+		entries[index] = benchEntry{Key: fmt.Sprint("key-", index), Value: float64(index) / 3, Tags: []string{"a", "b"}}
+	}
+	withLimits(b, len(entries))
+	benchmarkMap(b, memory.New(), &entries)
+}
+
+func BenchmarkMapLargeMap(b *testing.B) {
+	entries := make(map[string]*benchEntry, 20_000)
+	for index := 0; index < 20_000; index++ {
+		key := fmt.Sprint("key-", index)
+		entries[key] = &benchEntry{Key: key, Value: float64(index) / 3}
+	}
+	withLimits(b, len(entries))
+	benchmarkMap(b, memory.New(), &entries)
+}
+
+func BenchmarkMapTree100kParallel(b *testing.B) {
+	benchmarkMap(b, memory.New(memory.Parallel(4)), newBenchTree(100_000))
+}
@@ -0,0 +1,67 @@
+// github.com/seamia/memory/memorytest
+
+// Package memorytest contains helpers for using seamia/memory from tests.
+package memorytest
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/seamia/memory"
+)
+
+// DumpOnFailure renders the supplied values (using the default config) when the test fails
+func DumpOnFailure(t testing.TB, roots ...interface{}) {
+	t.Helper()
+	DumpOnFailureWith(t, memory.New(), roots...)
+}
+
+// DumpOnFailureWith renders the supplied values using the given config when the test fails.
+// the snapshot is written into os.TempDir() (and kept there, unlike t.TempDir()), the path is reported via t.Logf
+func DumpOnFailureWith(t testing.TB, config *memory.Config, roots ...interface{}) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+
+		f, err := os.CreateTemp("", fileName(t.Name())+"-*"+config.FileExtension())
+		if err != nil {
+			t.Logf("memorytest: failed to create snapshot file: %v", err)
+			return
+		}
+		defer f.Close()
+
+		info := func() map[string]string {
+			return map[string]string{"test": t.Name()}
+		}
+		// do not append into the caller's (variadic) backing array
+		args := make([]interface{}, 0, len(roots)+1)
+		args = append(args, roots...)
+		config.Map(f, append(args, memory.CustomInformation(info))...)
+
+		t.Logf("memorytest: memory snapshot written to %s", f.Name())
+	})
+}
+
+func fileName(testName string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, testName)
+}
+
+// AssertNoAliasing fails the test if a and b share any pointer, map, chan or slice backing array
+func AssertNoAliasing(t testing.TB, a, b interface{}) {
+	t.Helper()
+
+	aliases := memory.SharedReferences(a, b)
+	for _, alias := range aliases {
+		t.Errorf("memorytest: shared %s (%s) at 0x%x: %s and %s", alias.Kind, alias.Type, alias.Address, alias.PathA, alias.PathB)
+	}
+}
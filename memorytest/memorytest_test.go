@@ -0,0 +1,108 @@
+// github.com/seamia/memory/memorytest
+
+package memorytest_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/seamia/memory/memorytest"
+)
+
+// fakeTB records what the helpers do with the test (instead of failing the real one)
+type fakeTB struct {
+	testing.TB
+	name     string
+	failed   bool
+	cleanups []func()
+	logs     []string
+	errors   []string
+}
+
+func (f *fakeTB) Helper()                {}
+func (f *fakeTB) Name() string           { return f.name }
+func (f *fakeTB) Failed() bool           { return f.failed }
+func (f *fakeTB) Cleanup(cleanup func()) { f.cleanups = append(f.cleanups, cleanup) }
+func (f *fakeTB) Logf(format string, args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.failed = true
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+// finish runs the cleanups the way the testing package does (last registered first)
+func (f *fakeTB) finish() {
+	for index := len(f.cleanups) - 1; index >= 0; index-- {
+		f.cleanups[index]()
+	}
+}
+
+func TestDumpOnFailure(t *testing.T) {
+	type state struct {
+		Name  string
+		Items []int
+	}
+
+	for _, failed := range []bool{false, true} {
+		fake := &fakeTB{name: "TestSomething/sub case", failed: failed}
+		memorytest.DumpOnFailure(fake, &state{Name: "snapshot", Items: []int{1, 2, 3}})
+		fake.finish()
+
+		if !failed {
+			if len(fake.logs) > 0 {
+				t.Errorf("passing test: unexpected logs %q", fake.logs)
+			}
+			continue
+		}
+
+		if len(fake.logs) != 1 {
+			t.Fatalf("failing test: expected a single log, got %q", fake.logs)
+		}
+		const prefix = "memorytest: memory snapshot written to "
+		if !strings.HasPrefix(fake.logs[0], prefix) {
+			t.Fatalf("unexpected log %q", fake.logs[0])
+		}
+		path := strings.TrimPrefix(fake.logs[0], prefix)
+		t.Cleanup(func() { os.Remove(path) })
+		if !strings.HasPrefix(path, os.TempDir()) {
+			t.Errorf("snapshot %s not written into %s", path, os.TempDir())
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("snapshot not created: %v", err)
+		}
+		if !strings.Contains(string(data), "snapshot") || !strings.Contains(string(data), "TestSomething/sub case") {
+			t.Errorf("snapshot %s misses the value or the test name", path)
+		}
+	}
+}
+
+func TestAssertNoAliasing(t *testing.T) {
+	type object struct {
+		Items []int
+	}
+	items := []int{1, 2}
+
+	cases := []struct {
+		name   string
+		a, b   *object
+		failed bool
+	}{
+		{"independent", &object{Items: []int{1, 2}}, &object{Items: []int{1, 2}}, false},
+		{"shared slice", &object{Items: items}, &object{Items: items}, true},
+	}
+	for _, tc := range cases {
+		fake := &fakeTB{name: tc.name}
+		memorytest.AssertNoAliasing(fake, tc.a, tc.b)
+		if fake.failed != tc.failed {
+			t.Errorf("%s: failed = %v, want %v (%q)", tc.name, fake.failed, tc.failed, fake.errors)
+		}
+		if tc.failed && (len(fake.errors) != 1 || !strings.Contains(fake.errors[0], "(*a).Items and (*b).Items")) {
+			t.Errorf("%s: unexpected errors %q", tc.name, fake.errors)
+		}
+	}
+}
@@ -3,6 +3,7 @@
 package memory
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
@@ -10,43 +11,35 @@ import (
 	"reflect"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type cell struct {
-	port string
-	name string
-	kind CellType
+	port  string
+	name  string
+	kind  CellType
+	title string // tooltip (if other than the name)
 }
 
-func (c *cell) write(w io.Writer) {
-
-	out := func(format string, arg ...interface{}) {
-		fmt.Fprintf(w, format, arg...)
-	}
-
+func (c *cell) write(w io.Writer, p *palette) {
 	// name := htmlize(c.name)
 	// out("<TD BGCOLOR=\"%s\" PORT=\"%s\" ALIGN=\"%s\" TITLE=\"%s\"><i>%s</i></TD>", c.bgcolor, c.port, c.align, name, name)
 
-	render(out, c.kind, c.name, c.port)
+	p.render(w, c.kind, c.name, c.title, c.port)
 }
 
 type field struct {
 	cells []cell
 }
 
-func (f *field) write(w io.Writer) {
-
-	out := func(format string, arg ...interface{}) {
-		fmt.Fprintf(w, format, arg...)
-	}
-
-	out("<TR>")
+func (f *field) write(w io.Writer, p *palette) {
+	io.WriteString(w, "<TR>")
 	for _, entry := range f.cells {
-		entry.write(w)
+		entry.write(w, p)
 	}
-	out("</TR>")
+	io.WriteString(w, "</TR>")
 }
 
 type cnode struct {
@@ -54,6 +47,18 @@ type cnode struct {
 	name    string
 	tooltip string
 	fields  []field
+	cluster int  // index (1-based) of the named root owning this node; 0 - none
+	shared  bool // reachable from several roots
+
+	size     uintptr // memory taken by the value itself (see sizeOf)
+	extra    uintptr // memory the value (or its inlined rows) exclusively points to
+	shallow  uintptr // see measureSizes
+	retained uintptr
+	sizes    string // shallow/retained sizes shown in the header (if enabled)
+	heat     string // header color (heat map)
+	doc      string // shown as the tooltip (instead of the tooltip, which is still used to pick the color)
+
+	pool []cell // see addCells
 }
 
 func createNode(id nodeID, name string, tooltip string) *cnode {
@@ -63,69 +68,68 @@ func createNode(id nodeID, name string, tooltip string) *cnode {
 		tooltip: tooltip,
 	}
 
-	trace("create.node: %s [%v]\n", name, id)
+	traceTraversal("create.node: %s [%v]\n", name, id)
 	return &node
 }
 
 func (s *cnode) addFieldInlined(port string, name, summary string, kind CellType) {
-	s.fields = append(s.fields, field{
-		cells: []cell{
-			cell{
-				port: port,
-				name: name,
-				kind: Key,
-			},
-			cell{
-				name: summary,
-				kind: kind,
-			},
+	s.addCells(
+		cell{
+			port: port,
+			name: name,
+			kind: Key,
 		},
-	})
+		cell{
+			name: summary,
+			kind: kind,
+		},
+	)
 }
 
 func (s *cnode) addField(port string, name string, kind CellType) {
-	s.fields = append(s.fields, field{
-		cells: []cell{
-			cell{
-				port: port,
-				name: name,
-				kind: kind,
-			},
+	s.addCells(
+		cell{
+			port: port,
+			name: name,
+			kind: kind,
 		},
-	})
+	)
 }
 
 func (s *cnode) addFields(port1 string, name1 string, port2 string, name2 string) {
-	s.fields = append(s.fields, field{
-		cells: []cell{
-			cell{
-				port: port1,
-				name: name1,
-				kind: Key,
-			},
-			cell{
-				port: port2,
-				name: name2,
-				kind: Value,
-			},
+	s.addCells(
+		cell{
+			port: port1,
+			name: name1,
+			kind: Key,
 		},
-	})
+		cell{
+			port: port2,
+			name: name2,
+			kind: Value,
+		},
+	)
 }
 
 func (s *cnode) addCells(args ...cell) {
-	var cells []cell
-	for _, one := range args {
-		cells = append(cells, one)
-	}
-	if len(cells) > 0 {
+	if len(args) > 0 {
+		// the cells of all the fields share (the blocks of) a single backing array
+		start := len(s.pool)
+		s.pool = append(s.pool, args...)
 		s.fields = append(s.fields, field{
-			cells: cells,
+			cells: s.pool[start:len(s.pool):len(s.pool)],
 		})
 	} else {
 		warning("the imput was empty?")
 	}
 }
 
+// reserve makes room for the given number of (two cell) fields
+func (s *cnode) reserve(fields int) {
+	s.fields = make([]field, 0, fields)
+	s.pool = make([]cell, 0, 2*fields)
+}
+
 func (s *cnode) colspan() int {
 	span := 1
 	for _, entry := range s.fields {
@@ -136,36 +140,53 @@ func (s *cnode) colspan() int {
 	return span
 }
 
-func (s *cnode) write(w io.Writer) {
-	out := func(format string, arg ...interface{}) {
-		fmt.Fprintf(w, format, arg...)
-	}
-
+func (s *cnode) write(w io.Writer, p *palette) {
 	// 		Node_128	[shape=plaintext tooltip="*" label=<*>];
-	idPart := ""
+	tooltip := s.tooltip
+	if len(s.doc) > 0 {
+		tooltip = strings.ReplaceAll(dotString(s.doc), "\n", "\\n")
+	}
+	io.WriteString(w, "\t")
+	s.id.writeName(w)
+	writeStrings(w, "\t[shape=plaintext tooltip=\"", tooltip)
+	if len(s.sizes) > 0 {
+		writeStrings(w, ", ", s.sizes)
+	}
+	io.WriteString(w, "\" ")
 	if optionAllowMetadata {
-		idPart = fmt.Sprintf("id=\"%s\" ", s.id.getName())
+		io.WriteString(w, "id=\"")
+		s.id.writeName(w)
+		io.WriteString(w, "\" ")
 	}
-
-	out("\t%v	[shape=plaintext tooltip=\"%s\" %slabel=<", s.id.getName(), s.tooltip, idPart)
-
-	table := getProperties(Frame)
-	out("<TABLE BORDER=\"%s\" CELLBORDER=\"%s\" CELLSPACING=\"%s\" BGCOLOR=\"%s\">",
-		table["border"], table["cellborder"], table["cellspacing"], table["bgcolor"])
-
-	header := getProperties(Header)
-	header = customize(header, s.tooltip)
-	out("<TR><TD COLSPAN=\"%v\" PORT=\"%s\" BGCOLOR=\"%s\" ALIGN=\"%s\">%s</TD></TR>",
-		s.colspan(), portTitle,
-		header["bgcolor"], header["align"],
-		formattedText(Header, s.name))
+	io.WriteString(w, "label=<")
+
+	io.WriteString(w, p.frame)
+
+	header := p.styles[Header]
+	bgcolor := header.background
+	if s.shared {
+		header = p.styles[Shared]
+		bgcolor = header.background
+	} else if len(s.heat) > 0 {
+		bgcolor = s.heat
+	} else if color, found := p.color(s.tooltip); found {
+		bgcolor = color
+	}
+	writeStrings(w, "<TR><TD COLSPAN=\"", strconv.Itoa(s.colspan()), "\" PORT=\"", portTitle,
+		"\" BGCOLOR=\"", bgcolor, "\" ALIGN=\"", header.alignment, "\">",
+		header.open, s.header(), header.close, "</TD></TR>")
 
 	for _, entry := range s.fields {
-		entry.write(w)
+		entry.write(w, p)
 	}
-	out("</TABLE>")
+	io.WriteString(w, "</TABLE>>];\n")
+}
 
-	out(">];\n")
+func (s *cnode) header() string {
+	if len(s.sizes) > 0 {
+		return s.name + " (" + s.sizes + ")"
+	}
+	return s.name
 }
 
 func (m *mapper) addConnection(fromNode nodeID, port string, toNode nodeID, tooltip string, style connectionStyle) {
@@ -174,25 +195,135 @@ func (m *mapper) addConnection(fromNode nodeID, port string, toNode nodeID, tool
 		return
 	}
 
-	m.connections = append(m.connections, connection{
+	conn := connection{
 		fromNode: fromNode,
 		fromPort: port,
 		toNode:   toNode,
 		toPort:   portTitle,
 		tooltip:  tooltip,
 		style:    style,
-	})
+	}
+	if m.stream != nil {
+		m.stream.connection(conn)
+		return
+	}
+	m.connections = append(m.connections, conn)
 }
 
 func (m *mapper) addNode(node *cnode) {
+	if _, copied := m.copies[node.id]; copied {
+		node.name += " (copy)"
+	}
+	m.appendNode(node)
+}
+
+func (m *mapper) appendNode(node *cnode) {
+	if m.stream != nil {
+		m.stream.node(node)
+		return
+	}
 	m.nodes = append(m.nodes, node)
 }
 
+func (m *mapper) nodeCount() int {
+	if m.stream != nil {
+		return m.stream.nodes
+	}
+	return len(m.nodes)
+}
+
 func (m *mapper) write(w io.Writer) {
+	if m.stream != nil {
+		m.collectInfo()
+		m.stream.finish(m.properties)
+		return
+	}
+
+	switch m.config.format {
+	case FormatDominators:
+		m.collectInfo()
+		buffered := bufio.NewWriterSize(w, outputBufferSize)
+		defer buffered.Flush()
+		m.writeDominators(buffered)
+		return
+	case FormatPprof:
+		if err := m.writeProfile(w); err != nil {
+			warning("failed to write the profile: %v", err)
+		}
+		return
+	}
+
+	clusters := m.assignClusters()
+	if m.config.sizes || m.config.heatMap {
+		m.measureSizes()
+	}
 	m.optimize()
 	m.collectInfo()
+
+	buffered := bufio.NewWriterSize(w, outputBufferSize)
+	defer buffered.Flush()
+
 	// Mrecord(w, m.nodes, m.connections, m.comment)
-	mTable(w, m.nodes, m.connections, m.properties, m.comment)
+	mTable(buffered, m.config.getPalette(), m.nodes, m.connections, m.properties, m.comment, clusters)
+}
+
+// assignClusters places every node reachable from a single named root into that root's cluster
+// and marks the nodes reachable from several roots as shared. returns the names of the clusters
+func (m *mapper) assignClusters() []string {
+	named := false
+	for _, root := range m.roots {
+		if len(root.name) > 0 {
+			named = true
+		}
+	}
+	if !named {
+		return nil
+	}
+
+	direct := make(map[nodeID][]nodeID)
+	for _, conn := range m.connections {
+		direct[conn.fromNode] = append(direct[conn.fromNode], conn.toNode)
+	}
+
+	owners := make(map[nodeID][]int)
+	for index, root := range m.roots {
+		if root.id == 0 {
+			continue
+		}
+		visited := map[nodeID]bool{root.id: true}
+		pending := []nodeID{root.id}
+		for len(pending) > 0 {
+			current := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			owners[current] = append(owners[current], index)
+
+			for _, next := range direct[current] {
+				if !visited[next] {
+					visited[next] = true
+					pending = append(pending, next)
+				}
+			}
+		}
+	}
+
+	for _, node := range m.nodes {
+		switch found := owners[node.id]; {
+		case len(found) > 1:
+			node.shared = true
+		case len(found) == 1 && len(m.roots[found[0]].name) > 0:
+			node.cluster = found[0] + 1
+		}
+	}
+
+	var clusters, names []string
+	for _, root := range m.roots {
+		clusters = append(clusters, root.name)
+		if len(root.name) > 0 {
+			names = append(names, root.name)
+		}
+	}
+	m.addInfo("roots", "%s", strings.Join(names, ", "))
+	return clusters
 }
 
 func (m *mapper) collectInfo() {
@@ -214,7 +345,7 @@ func (m *mapper) collectInfo() {
 	m.addInfo("version", "%s", runtime.Version())
 
 	if len(m.comment) > 0 {
-		m.addInfo("comment", m.comment)
+		m.addInfo("comment", "%s", m.comment)
 	}
 
 	if host, err := os.Hostname(); err == nil {
@@ -252,44 +383,57 @@ func (m *mapper) optimize() {
 
 	if Options().CollapsePointerNodes || Options().CollapseSingleSliceNodes {
 
-		direct := make(map[nodeID][]nodeID)
-		reverse := make(map[nodeID][]nodeID)
+		// the ids are dense: index by them instead of using maps
+		size := len(m.nodeIDs)
+		for _, node := range m.nodes {
+			size = max(size, int(node.id)+1)
+		}
+		for _, conn := range m.connections {
+			size = max(size, int(conn.fromNode)+1, int(conn.toNode)+1)
+		}
 
+		outgoing := make([]int32, size) // number of the connections leaving the node
+		first := make([]nodeID, size)   // target of the first of them
 		for _, conn := range m.connections {
-			direct[conn.fromNode] = append(direct[conn.fromNode], conn.toNode)
-			reverse[conn.toNode] = append(reverse[conn.toNode], conn.fromNode)
+			if conn.fromNode < 0 || conn.toNode < 0 {
+				continue
+			}
+			if outgoing[conn.fromNode] == 0 {
+				first[conn.fromNode] = conn.toNode
+			}
+			outgoing[conn.fromNode]++
 		}
 
-		access := make(map[nodeID]*cnode)
+		access := make([]*cnode, size)
 		for _, node := range m.nodes {
-			access[node.id] = node
+			if node.id >= 0 {
+				access[node.id] = node
+			}
 		}
 
-		singleUse := make([]nodeID, 0)
+		collapsed := make([]bool, size)
 		remap := make(map[nodeID]nodeID)
 
 		for _, node := range m.nodes {
 			from := node.id
 
-			if len(direct[from]) == 1 {
-				to := direct[from][0]
+			if from >= 0 && outgoing[from] == 1 {
+				to := first[from]
 				// if len(reverse[to]) == 1 {
-				if len(node.fields) <= 1 { // == 0
+				if len(node.fields) <= 1 && access[to] != nil { // == 0
 					parts := strings.Split(node.name, ".")
 					suffix := parts[len(parts)-1]
 
 					toName := access[to].name
 					if toName == node.name || toName == suffix {
-
-						singleUse = append(singleUse, from)
+						collapsed[from] = true
 						remap[from] = to
 					}
 				}
 				// }
 			}
 		}
-
-		var connections []connection
+		connections := make([]connection, 0, len(m.connections))
 		for _, conn := range m.connections {
 			from := conn.fromNode
 			to := conn.toNode
@@ -311,20 +455,33 @@ func (m *mapper) optimize() {
 		}
 		m.connections = connections
 
-		for _, id := range singleUse {
-			delete(access, id)
-		}
-
-		var nodes []*cnode
-		for _, value := range access {
-			nodes = append(nodes, value)
+		// (the order of the nodes is kept, of those sharing an id the last one is)
+		nodes := make([]*cnode, 0, len(m.nodes)-len(remap))
+		for _, node := range m.nodes {
+			if node.id < 0 || (!collapsed[node.id] && access[node.id] == node) {
+				nodes = append(nodes, node)
+			}
 		}
 		m.nodes = nodes
 	}
 }
 
+const nodeNamePrefix = "Node_Ja_"
+
+const outputBufferSize = 64 << 10 // the output of large graphs is written in (much) fewer chunks
+
 func (node nodeID) getName() string {
-	return fmt.Sprintf("Node_Ja_%v", node)
+	return nodeNamePrefix + strconv.Itoa(int(node))
+}
+
+// writeName writes getName of the node (without building the string, when buffered)
+func (node nodeID) writeName(w io.Writer) {
+	if buffered, ok := w.(*bufio.Writer); ok {
+		name := append(buffered.AvailableBuffer(), nodeNamePrefix...)
+		buffered.Write(strconv.AppendInt(name, int64(node), 10))
+		return
+	}
+	io.WriteString(w, node.getName())
 }
 
 type info struct {
@@ -339,7 +496,7 @@ func (s *info) add(key string, format string, args ...interface{}) {
 	s.data[key] = fmt.Sprintf(format, args...)
 }
 
-func (s *info) write(w io.Writer) {
+func (s *info) write(w io.Writer, p *palette) {
 
 	if len(s.data) == 0 {
 		return
@@ -360,19 +517,19 @@ func (s *info) write(w io.Writer) {
 	*/
 
 	// 		Node_128	[shape=plaintext tooltip="*" label=<*>];
-	table := getProperties(InfoFrame)
+	table := p.properties(InfoFrame)
 	out("\t%v	[shape=plaintext fontsize=\"%s\" fillcolor=\"%s\" tooltip=\"%s\" label=<",
 		"Info", table["fontsize"], table[background], "")
 
 	out("<TABLE BORDER=\"%s\" CELLBORDER=\"%s\" CELLSPACING=\"%s\" BGCOLOR=\"%s\">",
 		table["border"], table["cellborder"], table["cellspacing"], table["bgcolor"])
 
-	header := getProperties(InfoHeader)
+	header := p.properties(InfoHeader)
 	// header = customize(header, s.tooltip)
 	out("<TR><TD COLSPAN=\"%v\" PORT=\"%s\" BGCOLOR=\"%s\" ALIGN=\"%s\">%s</TD></TR>",
 		2, portTitle,
 		header["bgcolor"], header["align"],
-		formattedText(Header, "Information"))
+		p.formattedText(Header, "Information"))
 
 	keys := make([]string, 0, len(s.data))
 	for key := range s.data {
@@ -386,8 +543,8 @@ func (s *info) write(w io.Writer) {
 		value := s.data[key]
 
 		out("<TR>")
-		render(out, InfoKey, key, "")
-		render(out, InfoValue, value, "")
+		p.render(w, InfoKey, key, "", "")
+		p.render(w, InfoValue, value, "", "")
 		out("</TR>")
 	}
 	out("</TABLE>")
@@ -0,0 +1,112 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// MapOption is an explicit (typed) argument of Map
+	MapOption func(*request)
+
+	request struct {
+		roots     []NamedRoot
+		comment   string
+		resolvers []CustomResolver
+		info      []CustomInformation
+		critical  func(func())
+		ctx       context.Context
+	}
+)
+
+// Values supplies the values to be mapped; unlike the legacy form, strings and funcs are mapped as values too
+func Values(values ...interface{}) MapOption {
+	return func(req *request) {
+		for _, value := range values {
+			if root, ok := value.(NamedRoot); ok {
+				req.roots = append(req.roots, root)
+			} else {
+				req.roots = append(req.roots, NamedRoot{Value: value})
+			}
+		}
+	}
+}
+
+// Comment sets the label of the generated graph
+func Comment(text string) MapOption {
+	return func(req *request) {
+		req.comment = text
+	}
+}
+
+// WithResolver adds a custom resolver used to render (inlined) values
+func WithResolver(resolver CustomResolver) MapOption {
+	return func(req *request) {
+		req.resolvers = append(req.resolvers, resolver)
+	}
+}
+
+// WithInfo adds a provider of additional entries for the info panel
+func WithInfo(inform CustomInformation) MapOption {
+	return func(req *request) {
+		req.info = append(req.info, inform)
+	}
+}
+
+// LockedBy makes Map take a consistent snapshot: the supplied lock is held while
+// the data is collected (and released before the output is rendered).
+// note: String()/Error() of the values are not called while the lock is held (they might need it as well),
+// the values are shown by their fields instead. the resolvers (see WithResolver) are still called and must not take the lock.
+// nothing is written to the output while the lock is held (not even in the Streaming mode)
+func LockedBy(lock sync.Locker) MapOption {
+	return Within(func(collect func()) {
+		lock.Lock()
+		defer lock.Unlock()
+		collect()
+	})
+}
+
+// Within makes Map collect the data inside the supplied critical section (e.g. a "run under lock" helper),
+// the output is rendered (and written, even in the Streaming mode) after the critical section is left
+// (see LockedBy for what is not called within it)
+func Within(critical func(collect func())) MapOption {
+	return func(req *request) {
+		req.critical = critical
+	}
+}
+
+// parseArguments translates the arguments of Map into a request.
+// MapOption(s) are applied as is, everything else is "sniffed" (the legacy form):
+// a string is the comment, a CustomResolver/CustomInformation is a resolver/info provider,
+// anything else is a value to be mapped
+func parseArguments(is []interface{}) *request {
+	req := &request{}
+
+	if lenis := len(is); lenis > 1 {
+		if txt, converts := is[lenis-1].(string); converts {
+			req.comment = txt
+		}
+	}
+
+	for _, i := range is {
+		switch actual := i.(type) {
+		case MapOption:
+			actual(req)
+		case string:
+			if len(req.comment) == 0 {
+				req.comment = actual
+			}
+		case CustomResolver:
+			req.resolvers = append(req.resolvers, actual)
+		case CustomInformation:
+			req.info = append(req.info, actual)
+		case NamedRoot:
+			req.roots = append(req.roots, actual)
+		default:
+			req.roots = append(req.roots, NamedRoot{Value: i})
+		}
+	}
+	return req
+}
@@ -0,0 +1,224 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// placeholderPrefix starts the (temporary) names of the anonymous structs met by the sub-mappers
+const placeholderPrefix = "\x00anonymous-"
+
+// subMapper returns a mapper for walking (concurrently with its siblings) a part of the graph:
+// everything already known to the parent is reused, everything new is collected locally (see merge)
+func (m *mapper) subMapper() *mapper {
+	return &mapper{
+		nil,
+		map[nodeKey]nodeID{nilKey: 0},
+		map[nodeKey]string{nilKey: "nil"},
+		m.inlineableItemLimit,
+		nil,
+		nil,
+		info{},
+		"",
+		map[uintptr]reflect.Value{},
+		m.currentRoot,
+		m.resolvers,
+		nil,
+		map[nodeID]reflect.Value{},
+		m.config,
+		0,
+		m.anonymous, // read only
+		m.ctx,
+		nil,
+		nil,
+		map[reflect.Type]*structPlan{},
+		map[reflect.Type][]int{},
+		m,
+		map[string]reflect.Type{},
+		map[nodeID]bool{},
+		m.snapshot,
+		map[uintptr][]nodeKey{},
+		nil,
+	}
+}
+
+// inherited returns the node (already) mapped by the parent of the sub-mapper
+func (m *mapper) inherited(key nodeKey) (nodeID, string, bool) {
+	if m.parent == nil || key.seq != 0 {
+		return 0, "", false
+	}
+	if summary, found := m.parent.nodeSummaries[key]; found && summary != reserved {
+		return m.parent.nodeIDs[key], summary, true
+	}
+	return 0, "", false
+}
+
+func (m *mapper) placeholder(uType reflect.Type) string {
+	name := fmt.Sprintf("%s%d", placeholderPrefix, len(m.placeholders))
+	m.placeholders[name] = uType
+	return name
+}
+
+// prefetch maps (in parallel, see Parallel) the supplied elements of a collection, so the subsequent
+// (sequential) mapping of the collection finds all of them already known
+func (m *mapper) prefetch(parentID nodeID, elements []reflect.Value) {
+	if m.config.workers < 2 || m.parent != nil || m.cancelled() {
+		return
+	}
+
+	var tasks []reflect.Value
+	for _, element := range elements {
+		if !element.IsValid() || m.isInlinableValue(element) {
+			continue
+		}
+		key := m.getNodeKey(element)
+		if _, known := m.nodeSummaries[key]; !known && key.seq == 0 {
+			tasks = append(tasks, element)
+		}
+	}
+	if len(tasks) < 2 {
+		return
+	}
+	m.indexAddresses() // (read by the sub-mappers, see capturedAt)
+
+	var (
+		subs    = make([]*mapper, len(tasks))
+		ids     = make([]nodeID, len(tasks))
+		failure interface{}
+		once    sync.Once
+		wg      sync.WaitGroup
+		workers = make(chan struct{}, m.config.workers)
+	)
+	for index, task := range tasks {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(index int, task reflect.Value) {
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { failure = r })
+				}
+				<-workers
+				wg.Done()
+			}()
+
+			sub := m.subMapper()
+			ids[index], _ = sub.mapValue(task, parentID, false)
+			subs[index] = sub
+		}(index, task)
+	}
+	wg.Wait()
+
+	if failure != nil {
+		panic(failure)
+	}
+
+	// merging in the order of the elements keeps the output deterministic
+	for index, sub := range subs {
+		m.merge(sub, ids[index])
+	}
+}
+
+// merge adds whatever the sub-mapper (rooted at the given node) collected and the parent does not know yet
+func (m *mapper) merge(sub *mapper, root nodeID) {
+	if sub.stopped != nil && m.stopped == nil {
+		m.stopped = sub.stopped
+	}
+
+	keys := make(map[nodeID]nodeKey, len(sub.nodeIDs))
+	for key, id := range sub.nodeIDs {
+		if id < 0 {
+			keys[id] = key
+		}
+	}
+
+	// nodes mapped meanwhile (by the parent or by the preceding siblings) are dropped...
+	remap := map[nodeID]nodeID{}
+	for id, key := range keys {
+		if key.seq != 0 {
+			continue
+		}
+		if known, found := m.nodeIDs[key]; found {
+			remap[id] = known
+		}
+	}
+
+	// ...as is everything reachable only through them
+	outgoing := map[nodeID][]nodeID{}
+	for _, conn := range sub.connections {
+		outgoing[conn.fromNode] = append(outgoing[conn.fromNode], conn.toNode)
+	}
+	retained := map[nodeID]bool{}
+	for pending := []nodeID{root}; len(pending) > 0; {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if _, dropped := remap[id]; id >= 0 || dropped || retained[id] {
+			continue
+		}
+		retained[id] = true
+		pending = append(pending, outgoing[id]...)
+	}
+
+	// the retained nodes get their (parent's) ids in the order the sub-mapper created them
+	order := make([]nodeID, 0, len(retained))
+	for id := range retained {
+		order = append(order, id)
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i] > order[j] })
+
+	for _, id := range order {
+		key := keys[id]
+		summary, found := sub.nodeSummaries[key]
+		if key.seq != 0 {
+			m.keyCounter++
+			key = nodeKey{kind: key.kind, seq: m.keyCounter}
+		}
+
+		remap[id] = nodeID(len(m.nodeIDs))
+		m.identify(key, remap[id])
+		if found {
+			m.nodeSummaries[key] = summary
+		}
+		if copied, found := sub.copies[id]; found {
+			m.copies[remap[id]] = copied
+		}
+		if sub.embedded[id] {
+			m.embedded[remap[id]] = true
+		}
+	}
+
+	// pointers, interfaces, ... (which do not get ids of their own)
+	for key, summary := range sub.nodeSummaries {
+		if _, known := m.nodeSummaries[key]; !known && key.seq == 0 && summary != reserved {
+			if _, identified := sub.nodeIDs[key]; !identified {
+				m.nodeSummaries[key] = summary
+			}
+		}
+	}
+
+	for _, node := range sub.nodes {
+		if retained[node.id] {
+			node.id = remap[node.id]
+			if strings.HasPrefix(node.name, placeholderPrefix) {
+				token, rest, _ := strings.Cut(node.name, " ")
+				node.name = strings.TrimSpace(m.getStructTypeName(sub.placeholders[token]) + " " + rest)
+			}
+			m.appendNode(node)
+		}
+	}
+
+	for _, conn := range sub.connections {
+		if retained[conn.fromNode] {
+			to := conn.toNode
+			if to < 0 {
+				to = remap[to]
+			}
+			m.addConnection(remap[conn.fromNode], conn.fromPort, to, conn.tooltip, conn.style)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"time"
+)
+
+// protobuf is a (minimal) encoder of the protocol buffers wire format
+type protobuf struct {
+	bytes.Buffer
+}
+
+const (
+	wireVarint    = 0
+	wireDelimited = 2
+)
+
+func (b *protobuf) varint(value uint64) {
+	for value >= 0x80 {
+		b.WriteByte(byte(value) | 0x80)
+		value >>= 7
+	}
+	b.WriteByte(byte(value))
+}
+
+func (b *protobuf) tag(field int, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (b *protobuf) uint64(field int, value uint64) {
+	if value != 0 {
+		b.tag(field, wireVarint)
+		b.varint(value)
+	}
+}
+
+func (b *protobuf) int64(field int, value int64) {
+	b.uint64(field, uint64(value))
+}
+
+func (b *protobuf) string(field int, value string) {
+	b.tag(field, wireDelimited)
+	b.varint(uint64(len(value)))
+	b.WriteString(value)
+}
+
+func (b *protobuf) message(field int, message *protobuf) {
+	b.tag(field, wireDelimited)
+	b.varint(uint64(message.Len()))
+	b.Write(message.Bytes())
+}
+
+func (b *protobuf) packed(field int, values []uint64) {
+	var content protobuf
+	for _, value := range values {
+		content.varint(value)
+	}
+	b.message(field, &content)
+}
+
+// fields of (the messages of) github.com/google/pprof/proto/profile.proto used below
+const (
+	profileSampleType  = 1
+	profileSample      = 2
+	profileLocation    = 4
+	profileFunction    = 5
+	profileStringTable = 6
+	profileTimeNanos   = 9
+	profilePeriodType  = 11
+	profilePeriod      = 12
+	valueTypeType      = 1
+	valueTypeUnit      = 2
+	sampleLocationID   = 1
+	sampleValue        = 2
+	locationID         = 1
+	locationLine       = 4
+	lineFunctionID     = 1
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+)
+
+// writeProfile writes the (gzipped) pprof profile of the captured graph: a sample per node
+// (its shallow size and count), with the path of the dominator tree leading to it as the "stack"
+func (m *mapper) writeProfile(w io.Writer) error {
+	nodes, tree := m.measureSizes()
+
+	var profile protobuf
+	table := []string{""}
+	index := map[string]uint64{"": 0}
+	str := func(text string) uint64 {
+		if found, exist := index[text]; exist {
+			return found
+		}
+		index[text] = uint64(len(table))
+		table = append(table, text)
+		return index[text]
+	}
+
+	valueType := func(field int, kind, unit string) {
+		var vt protobuf
+		vt.uint64(valueTypeType, str(kind))
+		vt.uint64(valueTypeUnit, str(unit))
+		profile.message(field, &vt)
+	}
+	valueType(profileSampleType, "objects", "count")
+	valueType(profileSampleType, "space", "bytes")
+
+	// a function (and a location) per distinct frame
+	frames := map[string]uint64{}
+	frame := func(name string) uint64 {
+		if id, found := frames[name]; found {
+			return id
+		}
+		id := uint64(len(frames) + 1)
+		frames[name] = id
+
+		var function protobuf
+		function.uint64(functionID, id)
+		function.uint64(functionName, str(name))
+		function.uint64(functionSystemName, str(name))
+		profile.message(profileFunction, &function)
+
+		var line, location protobuf
+		line.uint64(lineFunctionID, id)
+		location.uint64(locationID, id)
+		location.message(locationLine, &line)
+		profile.message(profileLocation, &location)
+		return id
+	}
+
+	names := m.frameNames(nodes, tree)
+	for _, id := range tree.order {
+		node, found := nodes[id]
+		if !found {
+			continue
+		}
+
+		// leaf first
+		var stack []uint64
+		for current := id; current != tree.root; current = tree.idom[current] {
+			stack = append(stack, frame(names[current]))
+		}
+
+		var sample protobuf
+		sample.packed(sampleLocationID, stack)
+		sample.packed(sampleValue, []uint64{1, uint64(node.shallow)})
+		profile.message(profileSample, &sample)
+	}
+
+	valueType(profilePeriodType, "space", "bytes")
+	profile.int64(profilePeriod, 1)
+	profile.int64(profileTimeNanos, time.Now().UnixNano())
+	for _, text := range table {
+		profile.string(profileStringTable, text)
+	}
+
+	compressed := gzip.NewWriter(w)
+	if _, err := compressed.Write(profile.Bytes()); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// frameNames names the nodes as seen from their immediate dominators: "<field>: <type>"
+func (m *mapper) frameNames(nodes map[nodeID]*cnode, tree dominatorTree) map[nodeID]string {
+	fields := map[[2]nodeID]string{}
+	for _, conn := range m.connections {
+		key := [2]nodeID{conn.fromNode, conn.toNode}
+		if _, found := fields[key]; !found {
+			fields[key] = strings.Trim(conn.tooltip, " \t\"\r\n")
+		}
+	}
+	roots := map[nodeID]string{}
+	for _, root := range m.roots {
+		roots[root.id] = root.name
+	}
+
+	names := make(map[nodeID]string, len(nodes))
+	for id, node := range nodes {
+		name := node.name
+		if field := fields[[2]nodeID{tree.idom[id], id}]; len(field) > 0 {
+			name = field + ": " + name
+		} else if root := roots[id]; len(root) > 0 {
+			name = root + ": " + name
+		}
+		names[id] = name
+	}
+	return names
+}
@@ -3,9 +3,12 @@
 package memory
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -31,6 +34,12 @@ const (
 	InfoHeader
 	InfoKey
 	InfoValue
+	Shared
+	Cluster
+	Failure
+	Padding
+	CacheLine
+	Special
 
 	background = "bgcolor"
 	alignment  = "align"
@@ -44,22 +53,169 @@ func valueCellType(isNil bool) CellType {
 	return Value
 }
 
-func render(out Writef, kind CellType, text string, port string) {
-	name := htmlize(text)
-	title := txt2title(text)
-	props := getProperties(kind)
+// palette holds the (immutable once built) visual properties used while rendering
+type palette struct {
+	colors     map[string]string
+	cells      map[CellType]m2s
+	connectors map[connectionStyle]m2s
+	links      map[connectionStyle]linkStyle // (pre-rendered) connectors
+	styles     []cellStyle                   // (pre-rendered) cells, by their type
+	frame      string                        // (pre-rendered) opening tag of the node tables
+}
 
-	if len(port) > 0 {
-		port = "PORT=\"" + port + "\" "
+type cellStyle struct {
+	prefix string // opening of the cell, up to the (optional) port
+	align  string // alignment, up to the title
+	open   string // formatting of the text (see formattedText)
+	close  string
+
+	background string // the properties themselves
+	alignment  string
+}
+
+type linkStyle struct {
+	attributes []string // sorted
+	port       string   // suffix of the "to" port
+}
+
+func newPalette(colors map[string]string) *palette {
+	p := &palette{
+		colors:     colors,
+		cells:      make(map[CellType]m2s, len(cellTypeProperties)),
+		connectors: make(map[connectionStyle]m2s, len(connectorProperties)),
+	}
+	for kind, props := range cellTypeProperties {
+		p.cells[kind] = copyMap(props)
+	}
+	for style, props := range connectorProperties {
+		p.connectors[style] = copyMap(props)
 	}
+	p.prepare()
+	return p
+}
 
-	out("<TD BGCOLOR=\"%s\" %sALIGN=\"%s\" TITLE=\"%s\">%s</TD>",
-		props[background], port, props[alignment], title, formattedText(kind, name))
+// prepare pre-renders the connector and cell properties
+func (p *palette) prepare() {
+	p.styles = make([]cellStyle, len(cellTypeName))
+	for kind := range p.styles {
+		p.styles[kind] = p.newCellStyle(CellType(kind))
+	}
+	table := p.properties(Frame)
+	p.frame = "<TABLE BORDER=\"" + table["border"] + "\" CELLBORDER=\"" + table["cellborder"] +
+		"\" CELLSPACING=\"" + table["cellspacing"] + "\" BGCOLOR=\"" + table["bgcolor"] + "\">"
+
+	p.links = make(map[connectionStyle]linkStyle, len(p.connectors))
+	for style, props := range p.connectors {
+		link := linkStyle{}
+		for prop, value := range props {
+			switch prop {
+			case "port":
+				link.port += ":" + value
+			case "color":
+				link.attributes = append(link.attributes, prop+"=\""+value+"\"")
+			default:
+				link.attributes = append(link.attributes, prop+"="+value)
+			}
+		}
+		sort.Strings(link.attributes)
+		p.links[style] = link
+	}
+}
 
+func (p *palette) clone() *palette {
+	colors := make(map[string]string, len(p.colors))
+	for k, v := range p.colors {
+		colors[k] = v
+	}
+	result := &palette{
+		colors:     colors,
+		cells:      make(map[CellType]m2s, len(p.cells)),
+		connectors: make(map[connectionStyle]m2s, len(p.connectors)),
+	}
+	for kind, props := range p.cells {
+		result.cells[kind] = copyMap(props)
+	}
+	for style, props := range p.connectors {
+		result.connectors[style] = copyMap(props)
+	}
+	result.prepare()
+	return result
+}
+
+func (p *palette) color(name string) (string, bool) {
+	if len(name) == 0 || len(p.colors) == 0 {
+		return "", false
+	}
+	result, found := p.colors[name]
+
+	if found {
+		trace("found custom color for (%s): %s", name, result)
+	}
+
+	return result, found
+}
+
+func (p *palette) newCellStyle(kind CellType) cellStyle {
+	props := p.properties(kind)
+	open, close := "", ""
+	switch props[text] {
+	case "bold":
+		open, close = "<b>", "</b>"
+	case "italic":
+		open, close = "<i>", "</i>"
+	case "underline":
+		open, close = "<u>", "</u>"
+	}
+	return cellStyle{
+		prefix: "<TD BGCOLOR=\"" + props[background] + "\" ",
+		align:  "ALIGN=\"" + props[alignment] + "\" TITLE=\"",
+		open:   open,
+		close:  close,
+
+		background: props[background],
+		alignment:  props[alignment],
+	}
 }
 
-func getProperty(kind CellType, key string) string {
-	if properties := getProperties(kind); len(properties) > 0 {
+func (p *palette) render(w io.Writer, kind CellType, text string, title string, port string) {
+	var style cellStyle
+	if int(kind) < len(p.styles) {
+		style = p.styles[kind]
+	} else {
+		style = p.newCellStyle(kind)
+	}
+	if len(title) == 0 {
+		title = text
+	}
+
+	// <TD BGCOLOR="%s" %sALIGN="%s" TITLE="%s">%s</TD>
+	writeStrings(w, style.prefix)
+	if len(port) > 0 {
+		writeStrings(w, "PORT=\"", port, "\" ")
+	}
+	writeStrings(w, style.align, txt2title(title), "\">", style.open, htmlize(text), style.close, "</TD>")
+}
+
+func writeStrings(w io.Writer, parts ...string) {
+	if buffered, ok := w.(*bufio.Writer); ok {
+		for _, part := range parts {
+			buffered.WriteString(part)
+		}
+		return
+	}
+	if sw, ok := w.(io.StringWriter); ok {
+		for _, part := range parts {
+			sw.WriteString(part)
+		}
+		return
+	}
+	for _, part := range parts {
+		io.WriteString(w, part)
+	}
+}
+
+func (p *palette) getProperty(kind CellType, key string) string {
+	if properties := p.properties(kind); len(properties) > 0 {
 		if value, found := properties[key]; found {
 			return value
 		}
@@ -67,8 +223,21 @@ func getProperty(kind CellType, key string) string {
 	return ""
 }
 
-func getProperties(kind CellType) m2s {
-	if properties, found := cellTypeProperties[kind]; found {
+// properties returns the (read only) properties of the cell type
+func (p *palette) properties(kind CellType) m2s {
+	if properties, found := p.cells[kind]; found {
+		return properties
+	}
+	return defaultProperties
+}
+
+var defaultProperties = m2s{
+	background: "#a6cee3",
+	alignment:  "right",
+}
+
+func (p *palette) getProperties(kind CellType) m2s {
+	if properties, found := p.cells[kind]; found {
 		return copyMap(properties)
 	}
 
@@ -118,8 +287,8 @@ func getProperties(kind CellType) m2s {
 	*/
 }
 
-func customize(original m2s, name string) m2s {
-	if color, found := GetColor(name); found {
+func (p *palette) customize(original m2s, name string) m2s {
+	if color, found := p.color(name); found {
 		original[background] = color
 	}
 
@@ -141,9 +310,9 @@ func customize(original m2s, name string) m2s {
 	return original
 }
 
-func formattedText(kind CellType, origin string) string {
+func (p *palette) formattedText(kind CellType, origin string) string {
 
-	switch getProperty(kind, text) {
+	switch p.getProperty(kind, text) {
 	case "bold":
 		return "<b>" + origin + "</b>"
 	case "italic":
@@ -171,8 +340,15 @@ var (
 		InfoHeader:       "info.header",
 		InfoKey:          "info.key",
 		InfoValue:        "info.value",
+		Shared:           "shared",
+		Cluster:          "cluster",
+		Failure:          "error",
+		Padding:          "padding",
+		CacheLine:        "cacheline",
+		Special:          "special",
 	}
 
+	// default properties (never modified: see palette)
 	cellTypeProperties = map[CellType]m2s{
 		Default: m2s{
 			background: "#a6cee3",
@@ -242,6 +418,33 @@ var (
 			alignment:  "left",
 			background: "floralwhite",
 		},
+		Shared: m2s{
+			alignment:  "right",
+			background: "gold",
+		},
+		Cluster: m2s{
+			background: "#f8f8ff",
+			"color":    "gray60",
+			"style":    "rounded,filled",
+		},
+		Failure: m2s{
+			alignment:  "left",
+			background: "#ff9f9f",
+		},
+		Padding: m2s{
+			alignment:  "left",
+			background: "#f0f0f0",
+			text:       "italic",
+		},
+		CacheLine: m2s{
+			alignment:  "left",
+			background: "#9fb7ff",
+		},
+		Special: m2s{
+			alignment:  "left",
+			background: "#ffd27f",
+			text:       "bold",
+		},
 	}
 
 	connectorProperties = map[connectionStyle]m2s{
@@ -326,17 +529,18 @@ func correctColor(original string) string {
 	return original
 }
 
-func applyProperties(from map[string]m2s) {
+func (p *palette) applyProperties(from map[string]m2s) {
 	for key, value := range from {
 		if ct, found := string2CellType(key); found {
-			if len(cellTypeProperties[ct]) == 0 {
-				cellTypeProperties[ct] = make(m2s)
+			if len(p.cells[ct]) == 0 {
+				p.cells[ct] = make(m2s)
 			}
 			for attribute, color := range value {
-				cellTypeProperties[ct][attribute] = correctColor(color)
+				p.cells[ct][attribute] = correctColor(color)
 			}
 		}
 	}
+	p.prepare()
 }
 
 func string2connectionStyle(name string) (connectionStyle, bool) {
@@ -353,11 +557,11 @@ func string2connectionStyle(name string) (connectionStyle, bool) {
 	return connDefault, false
 }
 
-func applyConnectors(from map[string]m2s) {
+func (p *palette) applyConnectors(from map[string]m2s) {
 	for key, value := range from {
 		if cs, found := string2connectionStyle(key); found {
-			if len(connectorProperties[cs]) == 0 {
-				connectorProperties[cs] = make(m2s)
+			if len(p.connectors[cs]) == 0 {
+				p.connectors[cs] = make(m2s)
 			}
 			for attribute, v := range value {
 				if attribute == "port" {
@@ -374,8 +578,9 @@ func applyConnectors(from map[string]m2s) {
 						continue
 					}
 				}
-				connectorProperties[cs][attribute] = v
+				p.connectors[cs][attribute] = v
 			}
 		}
 	}
+	p.prepare()
 }
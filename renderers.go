@@ -0,0 +1,237 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const timeFormat = "2006-01-02T15:04:05.999999999Z07:00 MST" // RFC3339 with the zone
+
+// timeRenderers show the values of the time package by their meaning rather than by their internals
+var timeRenderers = map[reflect.Type]CustomResolver{
+	reflect.TypeOf(time.Time{}): func(value reflect.Value) (string, bool) {
+		if !value.CanInterface() {
+			return "", false
+		}
+		return value.Interface().(time.Time).Format(timeFormat), true
+	},
+	reflect.TypeOf(time.Duration(0)): func(value reflect.Value) (string, bool) {
+		return time.Duration(value.Int()).String(), true
+	},
+	reflect.TypeOf(time.Month(0)): func(value reflect.Value) (string, bool) {
+		return time.Month(value.Int()).String(), true
+	},
+	reflect.TypeOf(time.Weekday(0)): func(value reflect.Value) (string, bool) {
+		return time.Weekday(value.Int()).String(), true
+	},
+	reflect.TypeOf(&time.Location{}): func(value reflect.Value) (string, bool) {
+		if value.IsNil() || !value.CanInterface() {
+			return "", false
+		}
+		return value.Interface().(*time.Location).String(), true
+	},
+	reflect.TypeOf(time.Location{}): func(value reflect.Value) (string, bool) {
+		if !value.CanAddr() {
+			return "", false
+		}
+		return reflect.NewAt(value.Type(), value.Addr().UnsafePointer()).Interface().(*time.Location).String(), true
+	},
+}
+
+// standardRenderers collapse the (commonly used) values of the standard library into a one line summary (see StandardRenderers)
+var standardRenderers = map[reflect.Type]methodRenderer{
+	reflect.TypeOf(net.IP{}):           stringerOf[net.IP](),
+	reflect.TypeOf(net.IPMask{}):       stringerOf[net.IPMask](),
+	reflect.TypeOf(net.IPNet{}):        stringerOf[*net.IPNet](),
+	reflect.TypeOf(&net.IPNet{}):       stringerOf[*net.IPNet](),
+	reflect.TypeOf(net.HardwareAddr{}): stringerOf[net.HardwareAddr](),
+	reflect.TypeOf(netip.Addr{}):       stringerOf[netip.Addr](),
+	reflect.TypeOf(netip.Prefix{}):     stringerOf[netip.Prefix](),
+	reflect.TypeOf(netip.AddrPort{}):   stringerOf[netip.AddrPort](),
+	reflect.TypeOf(url.URL{}):          {reflect.TypeOf(&url.URL{}), renderURL},
+	reflect.TypeOf(&url.URL{}):         {reflect.TypeOf(&url.URL{}), renderURL},
+	reflect.TypeOf(&big.Int{}):         stringerOf[*big.Int](),
+	reflect.TypeOf(big.Int{}):          stringerOf[*big.Int](),
+	reflect.TypeOf(&big.Rat{}):         stringerOf[*big.Rat](),
+	reflect.TypeOf(big.Rat{}):          stringerOf[*big.Rat](),
+	reflect.TypeOf(&big.Float{}):       {reflect.TypeOf(&big.Float{}), renderBigFloat},
+	reflect.TypeOf(big.Float{}):        {reflect.TypeOf(&big.Float{}), renderBigFloat},
+	reflect.TypeOf(&regexp.Regexp{}):   stringerOf[*regexp.Regexp](),
+	reflect.TypeOf(regexp.Regexp{}):    stringerOf[*regexp.Regexp](),
+	reflect.TypeOf(reflect.TypeOf(0)):  stringerOf[reflect.Type](),
+	reflect.TypeFor[reflect.Type]():    stringerOf[reflect.Type](),
+}
+
+// methodRenderer renders the values by the methods (String(), Redacted(), ...) of the receiver type
+type methodRenderer struct {
+	receiver reflect.Type // subject to the method call settings (see DisableMethodCalls, DenyMethodsOn) and to LockedBy
+	render   CustomResolver
+}
+
+func stringerOf[T fmt.Stringer]() methodRenderer {
+	return methodRenderer{reflect.TypeFor[T](), stringer[T]}
+}
+
+// fileRenderers do not call any methods (see renderFile)
+var fileRenderers = map[reflect.Type]CustomResolver{
+	reflect.TypeOf(&os.File{}): renderFile,
+}
+
+// nullableTypes are rendered by Config.nullable (see StandardRenderers)
+var nullableTypes = []reflect.Type{
+	reflect.TypeOf(sql.NullString{}),
+	reflect.TypeOf(sql.NullInt64{}),
+	reflect.TypeOf(sql.NullInt32{}),
+	reflect.TypeOf(sql.NullInt16{}),
+	reflect.TypeOf(sql.NullByte{}),
+	reflect.TypeOf(sql.NullFloat64{}),
+	reflect.TypeOf(sql.NullBool{}),
+	reflect.TypeOf(sql.NullTime{}),
+}
+
+// stringer renders the value by its String() method; the non pointer values are (when addressable) converted
+// to the pointer type T (e.g. big.Int => *big.Int)
+func stringer[T fmt.Stringer](value reflect.Value) (string, bool) {
+	if kind := value.Kind(); (kind == reflect.Pointer || kind == reflect.Interface) && value.IsNil() {
+		return "", false
+	}
+	if !value.Type().AssignableTo(reflect.TypeFor[T]()) {
+		if !value.CanAddr() {
+			return "", false
+		}
+		value = reflect.NewAt(value.Type(), value.Addr().UnsafePointer())
+	}
+	if !value.CanInterface() {
+		return "", false
+	}
+	return value.Interface().(T).String(), true
+}
+
+// renderURL shows the URL with the password (if any) redacted
+func renderURL(value reflect.Value) (string, bool) {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return "", false
+		}
+		value = value.Elem()
+	}
+	if !value.CanInterface() {
+		return "", false
+	}
+	u := value.Interface().(url.URL)
+	return u.Redacted(), true
+}
+
+func renderBigFloat(value reflect.Value) (string, bool) {
+	if value.Kind() != reflect.Pointer {
+		if !value.CanAddr() {
+			return "", false
+		}
+		value = value.Addr()
+	}
+	if value.IsNil() || !value.CanInterface() {
+		return "", false
+	}
+	return value.Interface().(*big.Float).Text('g', -1), true
+}
+
+// nullable renders the sql.Null* values: their value or NULL. the values of struct types (e.g. time.Time)
+// are rendered by the renderers of the config, if any (the value is explored otherwise, see DisableTimeRenderers)
+func (c *Config) nullable(value reflect.Value) (string, bool) {
+	if !value.Field(value.NumField() - 1).Bool() {
+		return "NULL", true
+	}
+	inner := value.Field(0)
+	switch inner.Kind() {
+	case reflect.String:
+		return strconv.Quote(inner.String()), true
+	case reflect.Struct:
+		if renderer, found := c.renderers[inner.Type()]; found {
+			return renderer(inner)
+		}
+		return "", false
+	}
+	if !inner.CanInterface() {
+		return "", false
+	}
+	return fmt.Sprint(inner.Interface()), true
+}
+
+// renderFile shows the name and the descriptor of the file, reading the latter directly:
+// (*os.File).Fd() would put the file into the blocking mode.
+// note: relies on the (unexported) layout of os.file: the descriptor is read from pfd.Sysfd (unix, windows, wasm),
+// on the platforms laying the file out differently (e.g. plan9) only the name is shown
+func renderFile(value reflect.Value) (string, bool) {
+	if value.IsNil() {
+		return "", false
+	}
+	file := value.Elem().Field(0) // *os.file
+	if file.Kind() != reflect.Pointer || file.IsNil() || file.Elem().Kind() != reflect.Struct {
+		return "", false
+	}
+	file = file.Elem()
+
+	name := file.FieldByName("name")
+	if name.Kind() != reflect.String {
+		return "", false
+	}
+	text := strconv.Quote(name.String())
+	if pfd := file.FieldByName("pfd"); pfd.Kind() != reflect.Struct {
+		return text, true
+	} else if fd := pfd.FieldByName("Sysfd"); fd.IsValid() {
+		if fd.CanInt() {
+			text += " (fd " + strconv.FormatInt(fd.Int(), 10) + ")"
+		} else if fd.CanUint() {
+			text += " (fd " + strconv.FormatUint(fd.Uint(), 10) + ")"
+		}
+	}
+	return text, true
+}
+
+// withRenderers returns the config's renderers extended with (or, if add is false, without) the given ones
+func (c *Config) withRenderers(renderers map[reflect.Type]CustomResolver, add bool) map[reflect.Type]CustomResolver {
+	result := make(map[reflect.Type]CustomResolver, len(c.renderers)+len(renderers))
+	for typ, renderer := range c.renderers {
+		result[typ] = renderer
+	}
+	for typ, renderer := range renderers {
+		if add {
+			result[typ] = renderer
+		} else {
+			delete(result, typ)
+		}
+	}
+	return result
+}
+
+// render renders the value by the renderer registered for its type (if any), guarded as the other user code (see invoke)
+func (m *mapper) render(value reflect.Value) (string, CellType, bool) {
+	if len(m.config.renderers) == 0 {
+		return "", Default, false
+	}
+	if renderer, found := m.config.renderers[value.Type()]; found {
+		if receiver, calls := m.config.receivers[value.Type()]; calls && !m.canCallMethodsOf(receiver) {
+			// the methods cannot be called (at least not now, see LockedBy): explored instead
+			return "", Default, false
+		}
+		txt, ok, err := m.invoke(func() (string, bool) {
+			return renderer(value)
+		})
+		if err != nil {
+			return err.Error(), Failure, true
+		}
+		return txt, Value, ok
+	}
+	return "", Default, false
+}
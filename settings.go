@@ -18,6 +18,7 @@ const (
 	optionAllowExternalResolver = true
 	optionAllowStringResolver   = true
 	optionAllowMetadata         = true
+	optionTraceTraversal        = false // very verbose (and slow) tracing of every visited value
 )
 
 type Settings struct {
@@ -40,6 +41,13 @@ type Settings struct {
 	PropsData                interface{}                  `json:"properties"`
 	Props                    map[string]map[string]string `json:"-"`
 	Connectors               map[string]map[string]string `json:"connectors"`
+	FloatFormat              string                       `json:"floatFormat"`    // g (default), e (scientific), f, ... (see strconv.FormatFloat)
+	FloatPrecision           int                          `json:"floatPrecision"` // -1: the smallest number of digits representing the value exactly
+	FloatHexBits             bool                         `json:"floatHexBits"`   // show the bit patterns of the floats
+	WrapStrings              int                          `json:"wrapStrings"`    // strings: max number of runes per line (0: no wrapping)
+	BytesFormat              string                       `json:"bytesFormat"`    // []byte: auto (default: text if printable, hex otherwise), text, hex, elements
+	MaxBytesLength           int                          `json:"maxBytesLength"` // []byte: max number of bytes shown
+	BytesPerLine             int                          `json:"bytesPerLine"`   // []byte: bytes per line of the hex dump
 
 	LoadedFrom string `json:"-"`
 }
@@ -55,54 +63,56 @@ var (
 		ColorDefault:             "whitesmoke",
 		FontName:                 "Cascadia Code",
 		FontSize:                 "10",
+		FloatFormat:              "g",
+		FloatPrecision:           -1,
+		MaxBytesLength:           256,
+		BytesPerLine:             16,
 	}
 
-	guard          sync.Mutex
-	customColors   = make(map[string]string)
-	settingsLoaded bool
+	settingsOnce sync.Once
+	basePalette  *palette // built (once) from the settings, never modified afterwards
 )
 
 func Options() *Settings {
-	if !settingsLoaded {
-		guard.Lock()
-		defer guard.Unlock()
-
-		if !settingsLoaded {
-			settingsLoaded = true
-
-			trace("loading settings")
-			loadedFrom := "./" + optionsFileName
-			data, err := os.ReadFile(loadedFrom)
-			if err != nil {
-				warning("failed to open file (%s): %v", loadedFrom, err)
-				loadedFrom = homeDir(optionsFileName)
-				data, err = os.ReadFile(loadedFrom)
-			}
-
-			if err == nil {
-				if err := json.Unmarshal(data, &settings); err != nil {
-					warning("error while loading config file (%v)", err)
-				} else {
-					settings.LoadedFrom = loadedFrom
-				}
-			} else {
-				if perr, found := err.(*fs.PathError); found && perr.Err == syscall.ERROR_FILE_NOT_FOUND {
-					// it is okay to have config file missing --> do not report this fact
-				} else {
-					warning("error while reading config file (%v)", err)
-				}
-			}
-
-			settings.ColorBackground = correctColor(settings.ColorBackground)
-			settings.ColorDefault = correctColor(settings.ColorDefault)
+	settingsOnce.Do(loadSettings)
+	return &settings
+}
 
-			loadColors()
+func loadSettings() {
+	trace("loading settings")
+	loadedFrom := "./" + optionsFileName
+	data, err := os.ReadFile(loadedFrom)
+	if err != nil {
+		warning("failed to open file (%s): %v", loadedFrom, err)
+		loadedFrom = homeDir(optionsFileName)
+		data, err = os.ReadFile(loadedFrom)
+	}
 
-			applyProperties(loadProps())
-			applyConnectors(settings.Connectors)
+	if err == nil {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			warning("error while loading config file (%v)", err)
+		} else {
+			settings.LoadedFrom = loadedFrom
+		}
+	} else {
+		if perr, found := err.(*fs.PathError); found && perr.Err == syscall.ERROR_FILE_NOT_FOUND {
+			// it is okay to have config file missing --> do not report this fact
+		} else {
+			warning("error while reading config file (%v)", err)
 		}
 	}
-	return &settings
+
+	settings.ColorBackground = correctColor(settings.ColorBackground)
+	settings.ColorDefault = correctColor(settings.ColorDefault)
+
+	basePalette = newPalette(loadColors())
+	basePalette.applyProperties(loadProps())
+	basePalette.applyConnectors(settings.Connectors)
+}
+
+func defaultPalette() *palette {
+	Options()
+	return basePalette
 }
 
 /*
@@ -111,10 +121,11 @@ the "colors" entry of the config file can be of these three types:
 2. []string - names of the files containing color definitions to be combined
 3. map[string]string - actual color definitions
 */
-func loadColors() {
+func loadColors() map[string]string {
+	customColors := make(map[string]string)
 	input := settings.Colors
 	if input == nil {
-		return
+		return customColors
 	}
 	trace("loading custom colors")
 
@@ -135,11 +146,11 @@ func loadColors() {
 				customColors[k] = txt
 			}
 		}
-		return
+		return customColors
 
 	default:
 		warning("unrecognized format of Colors section of the config file (%v)", actual)
-		return
+		return customColors
 	}
 
 	for _, entry := range list {
@@ -158,19 +169,11 @@ func loadColors() {
 			warning("error (%v) while loading config file (%v)", err, entry)
 		}
 	}
+	return customColors
 }
 
 func GetColor(name string) (string, bool) {
-	if len(name) == 0 || len(customColors) == 0 {
-		return "", false
-	}
-	result, found := customColors[name]
-
-	if found {
-		trace("found custom color for (%s): %s", name, result)
-	}
-
-	return result, found
+	return defaultPalette().color(name)
 }
 
 func homeDir(name string) string {
@@ -0,0 +1,168 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+const (
+	mapHeaderSize  = 48 // runtime.hmap
+	chanHeaderSize = 96 // runtime.hchan
+	bucketEntries  = 8
+	loadFactor     = 6.5
+
+	heatLow  = 0xffffcc // colors of the smallest/largest (retained) nodes, see HeatMap
+	heatHigh = 0xf03b20
+)
+
+// sizeOf returns the memory taken by the value itself (size) and by whatever it exclusively points to (extra)
+func sizeOf(val reflect.Value) (size, extra uintptr) {
+	typ := val.Type()
+	switch val.Kind() {
+	case reflect.Slice:
+		// the header is a part of whatever holds the slice: the node stands for the backing array
+		return 0, uintptr(val.Cap()) * typ.Elem().Size()
+	case reflect.Map:
+		return 0, mapSize(typ, val.Len())
+	case reflect.Chan:
+		if val.IsNil() {
+			return 0, 0
+		}
+		return 0, chanHeaderSize + uintptr(val.Cap())*typ.Elem().Size()
+	case reflect.Func:
+		return 0, 0
+	case reflect.String:
+		return typ.Size(), uintptr(val.Len())
+	}
+	return typ.Size(), 0
+}
+
+// inlineSize returns the memory referenced by the value shown inline (as a row of another node)
+func inlineSize(val reflect.Value) uintptr {
+	if !val.IsValid() {
+		return 0
+	}
+
+	switch val.Kind() {
+	case reflect.String:
+		return uintptr(val.Len())
+	case reflect.Slice:
+		return uintptr(val.Cap()) * val.Type().Elem().Size()
+	case reflect.Map:
+		if val.IsNil() {
+			return 0
+		}
+		return mapSize(val.Type(), val.Len())
+	case reflect.Interface:
+		if val.IsNil() {
+			return 0
+		}
+		elem := val.Elem()
+		size := inlineSize(elem)
+		if kind := elem.Kind(); kind != reflect.Pointer && kind != reflect.Map && kind != reflect.Chan && kind != reflect.Func && kind != reflect.UnsafePointer {
+			// not pointer shaped: the value is boxed
+			size += elem.Type().Size()
+		}
+		return size
+	}
+	return 0
+}
+
+// mapSize estimates the memory taken by a map: the header plus buckets with the entries
+func mapSize(typ reflect.Type, entries int) uintptr {
+	buckets := 1
+	for float64(entries) > loadFactor*float64(buckets) {
+		buckets *= 2
+	}
+	bucket := bucketEntries + bucketEntries*(typ.Key().Size()+typ.Elem().Size()) + unsafe.Sizeof(uintptr(0))
+	return mapHeaderSize + uintptr(buckets)*bucket
+}
+
+// measure sets the size of the node representing the value
+func (s *cnode) measure(val reflect.Value) {
+	s.size, s.extra = sizeOf(val)
+}
+
+// measureSizes computes the shallow and retained sizes of all the nodes, returns the nodes (by id) and their dominators
+func (m *mapper) measureSizes() (map[nodeID]*cnode, dominatorTree) {
+	nodes := make(map[nodeID]*cnode, len(m.nodes))
+	for _, node := range m.nodes {
+		if _, found := nodes[node.id]; !found {
+			nodes[node.id] = node
+		}
+		node.shallow = node.extra
+		if !m.embedded[node.id] {
+			node.shallow += node.size
+		}
+	}
+
+	graph := m.dominators()
+	retained := make(map[nodeID]uintptr, len(graph.order))
+	for _, id := range graph.order {
+		if node, found := nodes[id]; found {
+			retained[id] = node.shallow
+		}
+	}
+	for index := len(graph.order) - 1; index >= 0; index-- {
+		id := graph.order[index]
+		if parent, found := graph.idom[id]; found && parent != graph.root {
+			retained[parent] += retained[id]
+		}
+	}
+
+	var total, largest uintptr
+	for _, node := range m.nodes {
+		node.retained = retained[node.id]
+		if m.config.sizes || m.config.format == FormatDominators {
+			node.sizes = "shallow: " + byteSize(node.shallow) + ", retained: " + byteSize(node.retained)
+		}
+		total += node.shallow
+		if node.retained > largest {
+			largest = node.retained
+		}
+	}
+
+	if m.config.heatMap {
+		for _, node := range m.nodes {
+			node.heat = heatColor(node.retained, largest)
+		}
+	}
+	m.addInfo("total size", "%s (%d nodes)", byteSize(total), len(nodes))
+	return nodes, graph
+}
+
+// heatColor returns the color (on the logarithmic scale of sizes) of the node of the given size
+func heatColor(size, largest uintptr) string {
+	ratio := 0.0
+	if largest > 1 {
+		ratio = math.Log1p(float64(size)) / math.Log1p(float64(largest))
+	}
+
+	blend := func(shift uint) int {
+		low, high := float64(int(heatLow)>>shift&0xff), float64(int(heatHigh)>>shift&0xff)
+		return int(math.Round(low + (high-low)*ratio))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", blend(16), blend(8), blend(0))
+}
+
+// byteSize returns the human friendly form of the size
+func byteSize(size uintptr) string {
+	const unit = 1024
+	if size < unit {
+		return strconv.FormatUint(uint64(size), 10) + " B"
+	}
+
+	value, suffix := float64(size)/unit, "KiB"
+	for _, next := range []string{"MiB", "GiB", "TiB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + " " + suffix
+}
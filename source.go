@@ -0,0 +1,309 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// MapSource draws the schema of the types declared in the Go package(s) found in the given folder (using the default config)
+func MapSource(w io.Writer, dir string, is ...interface{}) error {
+	return defaultConfig().MapSource(w, dir, is...)
+}
+
+// MapSource draws the schema of the types declared in the Go package(s) found in the given folder, without running
+// (or building) them: a node per struct, interface and named type, a row per field (method, for interfaces),
+// the field/embedding relationships as connections and the doc comments as tooltips.
+// the rest of the arguments are the usual comment/info ones (see Map)
+func (c *Config) MapSource(w io.Writer, dir string, is ...interface{}) error {
+	packages, err := parseSource(dir)
+	if err != nil {
+		return err
+	}
+
+	w, done, err := c.output(w)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	m := c.newMapper(w, parseArguments(is))
+	for _, pkg := range packages {
+		schema := &sourceSchema{m, pkg.types, sourceDocs(pkg.files), map[*types.TypeName]nodeID{}}
+		scope := pkg.types.Scope()
+		for _, object := range scope.Names() {
+			if named, ok := scope.Lookup(object).(*types.TypeName); ok && !named.IsAlias() {
+				m.roots = append(m.roots, rootEntry{"", schema.node(named)})
+			}
+		}
+	}
+
+	m.addInfo("source", "%s", dir)
+	m.write(w)
+	return nil
+}
+
+type sourcePackage struct {
+	files []*ast.File
+	types *types.Package
+}
+
+// parseSource parses (and type checks) the packages found in the folder, sorted by name.
+// only the files matching the build constraints (of the current platform) are used, as by go build.
+// the imports are resolved from source (offline), whatever cannot be resolved is tolerated
+func parseSource(dir string) ([]sourcePackage, error) {
+	fset := token.NewFileSet()
+	packages, err := parser.ParseDir(fset, dir, func(info fs.FileInfo) bool {
+		if strings.HasSuffix(info.Name(), "_test.go") {
+			return false
+		}
+		// (the files failing to be matched are left to the parser to report)
+		match, err := build.Default.MatchFile(dir, info.Name())
+		return match || err != nil
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]sourcePackage, 0, len(names))
+	for _, name := range names {
+		pkg := packages[name]
+		files := make([]*ast.File, 0, len(pkg.Files))
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+
+		config := types.Config{
+			Importer: importer.ForCompiler(fset, "source", nil),
+			Error:    func(error) {},
+		}
+		checked, _ := config.Check(name, fset, files, nil)
+		if checked == nil {
+			warning("failed to type check package %s", name)
+			continue
+		}
+		result = append(result, sourcePackage{files, checked})
+	}
+	return result, nil
+}
+
+// sourceDocs collects the doc comments (and the line comments) of the types, fields and methods, keyed by the position of their names
+func sourceDocs(files []*ast.File) map[token.Pos]string {
+	docs := map[token.Pos]string{}
+	text := func(groups ...*ast.CommentGroup) string {
+		var parts []string
+		for _, group := range groups {
+			if part := strings.TrimSpace(group.Text()); len(part) > 0 {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+
+	// the doc comment of "type X ..." belongs to the declaration, not to the (single) spec
+	declared := map[*ast.TypeSpec]*ast.CommentGroup{}
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.GenDecl:
+				if node.Tok == token.TYPE && len(node.Specs) == 1 && node.Doc != nil {
+					declared[node.Specs[0].(*ast.TypeSpec)] = node.Doc
+				}
+			case *ast.TypeSpec:
+				group := node.Doc
+				if group == nil {
+					group = declared[node]
+				}
+				if doc := text(group, node.Comment); len(doc) > 0 {
+					docs[node.Name.Pos()] = doc
+				}
+			case *ast.Field:
+				doc := text(node.Doc, node.Comment)
+				if len(doc) == 0 {
+					break
+				}
+				for _, name := range node.Names {
+					docs[name.Pos()] = doc
+				}
+				if len(node.Names) == 0 {
+					docs[embeddedName(node.Type).Pos()] = doc
+				}
+			}
+			return true
+		})
+	}
+	return docs
+}
+
+// embeddedName returns the identifier naming the embedded field
+func embeddedName(expr ast.Expr) ast.Expr {
+	switch typed := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(typed.X)
+	case *ast.SelectorExpr:
+		return typed.Sel
+	case *ast.IndexExpr:
+		return embeddedName(typed.X)
+	case *ast.IndexListExpr:
+		return embeddedName(typed.X)
+	}
+	return expr
+}
+
+type sourceSchema struct {
+	m     *mapper
+	pkg   *types.Package
+	docs  map[token.Pos]string
+	nodes map[*types.TypeName]nodeID
+}
+
+func (s *sourceSchema) typeString(typ types.Type) string {
+	return types.TypeString(typ, func(pkg *types.Package) string {
+		if pkg == s.pkg {
+			return ""
+		}
+		return pkg.Name()
+	})
+}
+
+// node adds the node describing the named type (declared in the package)
+func (s *sourceSchema) node(named *types.TypeName) nodeID {
+	if id, found := s.nodes[named]; found {
+		return id
+	}
+	m := s.m
+	m.keyCounter++
+	id := nodeID(len(m.nodeIDs))
+	m.nodeIDs[nodeKey{seq: m.keyCounter}] = id
+	s.nodes[named] = id
+
+	qualified := s.pkg.Name() + "." + named.Name()
+	link := func(port string, to types.Type, tooltip string, style connectionStyle) {
+		targets, styles := s.referenced(to, style)
+		for index, target := range targets {
+			m.addConnection(id, port, s.node(target), tooltip, styles[index])
+		}
+	}
+
+	var snode *cnode
+	switch underlying := named.Type().Underlying().(type) {
+	case *types.Struct:
+		snode = createNode(id, named.Name(), "struct: "+qualified)
+		for index := 0; index < underlying.NumFields(); index++ {
+			field := underlying.Field(index)
+			name := field.Name()
+			if field.Embedded() {
+				name = "(embedded) " + name
+			}
+			doc := s.docs[field.Pos()]
+			snode.addCells(
+				cell{port: getStructRef(index), name: name, kind: Key, title: doc},
+				cell{port: getStructOutgoing(index), name: s.typeString(field.Type()), kind: Type, title: doc})
+			link(getStructOutgoing(index), field.Type(), field.Name(), connInner)
+		}
+
+	case *types.Interface:
+		snode = createNode(id, named.Name(), "interface: "+qualified)
+		for index := 0; index < underlying.NumMethods(); index++ {
+			method := underlying.Method(index)
+			doc := s.docs[method.Pos()]
+			snode.addCells(
+				cell{port: getStructRef(index), name: method.Name(), kind: Key, title: doc},
+				cell{port: getStructOutgoing(index), name: strings.TrimPrefix(s.typeString(method.Type()), "func"), kind: Type, title: doc})
+			link(getStructOutgoing(index), method.Type(), method.Name(), connDefault)
+		}
+		if underlying.NumMethods() == 0 {
+			snode.addField("", "(no methods)", Blank)
+		}
+
+	default:
+		snode = createNode(id, named.Name(), kindOf(underlying)+": "+qualified)
+		snode.addCells(cell{port: getStructRef(0), name: "underlying", kind: Key}, cell{port: getStructOutgoing(0), name: s.typeString(underlying), kind: Type})
+		link(getStructOutgoing(0), underlying, "", connDefault)
+	}
+
+	snode.doc = s.docs[named.Pos()]
+	m.addNode(snode)
+	return id
+}
+
+// referenced returns the named types (declared in the package) the type refers to, along with the style of the reference
+func (s *sourceSchema) referenced(typ types.Type, style connectionStyle) (targets []*types.TypeName, styles []connectionStyle) {
+	add := func(elem types.Type, kind connectionStyle) {
+		if style != connInner {
+			// the outermost constructor defines the style
+			kind = style
+		}
+		t, k := s.referenced(elem, kind)
+		targets, styles = append(targets, t...), append(styles, k...)
+	}
+
+	switch typed := typ.(type) {
+	case *types.Named:
+		if object := typed.Obj(); object.Pkg() == s.pkg && object.Parent() == s.pkg.Scope() {
+			if _, isInterface := typed.Underlying().(*types.Interface); isInterface && style == connInner {
+				style = connPointer
+			}
+			targets, styles = append(targets, object), append(styles, style)
+		}
+		if args := typed.TypeArgs(); args != nil {
+			for index := 0; index < args.Len(); index++ {
+				add(args.At(index), connDefault)
+			}
+		}
+	case *types.Pointer:
+		add(typed.Elem(), connPointer)
+	case *types.Slice:
+		add(typed.Elem(), connArray)
+	case *types.Array:
+		add(typed.Elem(), connArray)
+	case *types.Chan:
+		add(typed.Elem(), connDefault)
+	case *types.Map:
+		add(typed.Key(), connDefault)
+		add(typed.Elem(), connDefault)
+	case *types.Signature:
+		for _, tuple := range []*types.Tuple{typed.Params(), typed.Results()} {
+			for index := 0; index < tuple.Len(); index++ {
+				add(tuple.At(index).Type(), connDefault)
+			}
+		}
+	}
+	return targets, styles
+}
+
+// kindOf returns the (reflect like) kind of the underlying type
+func kindOf(typ types.Type) string {
+	switch typed := typ.(type) {
+	case *types.Basic:
+		return typed.Name()
+	case *types.Pointer:
+		return "ptr"
+	case *types.Slice:
+		return "slice"
+	case *types.Array:
+		return "array"
+	case *types.Map:
+		return "map"
+	case *types.Chan:
+		return "chan"
+	case *types.Signature:
+		return "func"
+	}
+	return "type"
+}
@@ -0,0 +1,99 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// stream writes the nodes as soon as they are completed (instead of accumulating them).
+// the connections are buffered (in a temp file, if possible) and written after all the nodes.
+// optimizations requiring the knowledge of the whole graph (e.g. collapsing of the nodes, clusters) are skipped
+type stream struct {
+	w       *bufio.Writer
+	palette *palette
+	nodes   int
+
+	held   *bytes.Buffer // holds the output produced while a lock is held (see Within)
+	target io.Writer     // receives the held output once the lock is released
+
+	spill       *os.File      // temp file holding the connections
+	buffer      *bytes.Buffer // holds the connections if the temp file cannot be used
+	connections *bufio.Writer
+}
+
+func newStream(w io.Writer, p *palette, comment string, critical bool) *stream {
+	s := &stream{
+		w:       bufio.NewWriter(w),
+		palette: p,
+	}
+	if critical {
+		// the caller's writer might need the very lock being held: nothing is written to it until finish
+		s.held = &bytes.Buffer{}
+		s.target = w
+		s.w = bufio.NewWriter(s.held)
+	}
+
+	if f, err := os.CreateTemp("", "seamia-memory-*.dot"); err == nil {
+		s.spill = f
+		s.connections = bufio.NewWriter(f)
+	} else {
+		warning("failed to create temp file (%v), connections are kept in memory", err)
+		s.buffer = &bytes.Buffer{}
+		s.connections = bufio.NewWriter(s.buffer)
+	}
+
+	tableProlog(s.w, comment)
+	return s
+}
+
+func (s *stream) node(node *cnode) {
+	s.nodes++
+	node.write(s.w, s.palette)
+}
+
+func (s *stream) connection(conn connection) {
+	conn.write(s.connections, s.palette)
+}
+
+func (s *stream) finish(props info) {
+	fmt.Fprintf(s.w, "\n\t/* ------ connections ------ */\n")
+
+	if err := s.connections.Flush(); err != nil {
+		warning("failed to write connections (%v)", err)
+	}
+
+	if s.spill != nil {
+		defer s.close()
+
+		if _, err := s.spill.Seek(0, io.SeekStart); err == nil {
+			if _, err := io.Copy(s.w, s.spill); err != nil {
+				warning("failed to copy connections (%v)", err)
+			}
+		} else {
+			warning("failed to rewind connections file (%v)", err)
+		}
+	} else if s.buffer != nil {
+		s.buffer.WriteTo(s.w)
+	}
+
+	tableEpilog(s.w, s.palette, props)
+	s.w.Flush()
+
+	if s.held != nil {
+		s.held.WriteTo(s.target)
+	}
+}
+
+// close removes the temp file holding the connections (if still around)
+func (s *stream) close() {
+	if s.spill != nil {
+		s.spill.Close()
+		os.Remove(s.spill.Name())
+		s.spill = nil
+	}
+}
@@ -7,25 +7,94 @@ import (
 	"reflect"
 )
 
-var (
-	structMapping        = make(map[string]string)
-	structMappingCounter int
-)
-
-func getStructTypeName(uType reflect.Type) string {
+func (m *mapper) getStructTypeName(uType reflect.Type) string {
 	structTypeName := uType.Name()
 	if len(structTypeName) == 0 {
 		structTypeName = uType.String()
 
-		if previous, exist := structMapping[structTypeName]; exist {
+		if previous, exist := m.anonymous[structTypeName]; exist {
 			return previous
 		}
+		if m.parent != nil {
+			return m.placeholder(uType)
+		}
 
-		newName := fmt.Sprintf("anonymous-%v", structMappingCounter)
-		structMappingCounter++
-
-		structMapping[structTypeName] = newName
+		newName := fmt.Sprintf("anonymous-%v", len(m.anonymous))
+		m.anonymous[structTypeName] = newName
 		return newName
 	}
 	return structTypeName
 }
+
+// structPlan caches everything (per struct type) that does not depend on the actual value
+type structPlan struct {
+	name    string // see getStructTypeName
+	summary string
+	tooltip string
+	fields  []fieldPlan
+	isError bool // the struct (or the pointer to it) implements error (see mapError)
+}
+
+type fieldPlan struct {
+	name     string
+	typeName string // see getTypeName
+	path     string // "struct:Type.field" (see Settings.Substitute), empty for the anonymous structs
+}
+
+func (m *mapper) planOf(uType reflect.Type) *structPlan {
+	if plan, found := m.plans[uType]; found {
+		return plan
+	}
+	if m.parent != nil {
+		if plan, found := m.parent.plans[uType]; found {
+			return plan
+		}
+	}
+
+	summary := escapeString(uType.String())
+	plan := &structPlan{
+		name:    m.getStructTypeName(uType),
+		summary: summary,
+		tooltip: "struct: " + summary,
+		fields:  make([]fieldPlan, uType.NumField()),
+		isError: reflect.PointerTo(uType).Implements(errorType),
+	}
+	for index := range plan.fields {
+		field := uType.Field(index)
+		plan.fields[index] = fieldPlan{
+			name:     field.Name,
+			typeName: getTypeName(field.Type),
+		}
+		if len(uType.Name()) > 0 {
+			// (the fields of the anonymous structs have no path: "struct:.field" would match those of all of them)
+			plan.fields[index].path = "struct:" + uType.Name() + "." + field.Name
+		}
+	}
+
+	m.plans[uType] = plan
+	return plan
+}
+
+// methodsOf returns the indexes of the String()/Error() methods (see resolvedMethods) of the type, -1 if absent
+func (m *mapper) methodsOf(typ reflect.Type) []int {
+	if indexes, found := m.methods[typ]; found {
+		return indexes
+	}
+
+	indexes := make([]int, len(resolvedMethods))
+	for i, name := range resolvedMethods {
+		indexes[i] = -1
+		if method, found := typ.MethodByName(name); found {
+			in := 0
+			if typ.Kind() != reflect.Interface {
+				in = 1 // the receiver
+			}
+			if mt := method.Type; mt.NumIn() == in && mt.NumOut() == 1 && mt.Out(0).Kind() == reflect.String {
+				indexes[i] = method.Index
+			}
+		}
+	}
+
+	m.methods[typ] = indexes
+	return indexes
+}
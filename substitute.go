@@ -0,0 +1,121 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"go/constant"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SubstitutesFrom generates the "substitute" tables (see Settings.Substitute) for the named integer types
+// declared in the Go package(s) found in the given folder: every typed constant becomes an entry of the table of its type.
+// the types whose (non zero) constants are distinct powers of two (or combinations of them, e.g. ModePerm = R|W|X),
+// but not a contiguous 1, 2, (3...) sequence, are treated as bit flags: their tables are marked as such (see substituteFlags),
+// so the combinations get decomposed (e.g. 5 => "FlagA|FlagC").
+// note: a type with just the 1 and 2 constants is taken for an enumeration; add "(flags)": "true" to its table if it is not
+func SubstitutesFrom(dir string) (map[string]map[string]string, error) {
+	packages, err := parseSource(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]map[string]string{}
+	for _, pkg := range packages {
+		for name, values := range typedConstants(pkg.types) {
+			if _, found := result[name]; found {
+				warning("type %s is declared in more than one package, keeping the first one", name)
+				continue
+			}
+			result[name] = substituteTable(values)
+		}
+	}
+	return result, nil
+}
+
+type namedConstant struct {
+	name  string
+	value uint64 // two's complement of the negative ones
+	text  string // the value, as rendered by Map
+}
+
+// typedConstants returns the constants of the named integer types declared in the package, grouped by the type name,
+// in the order of their declaration
+func typedConstants(pkg *types.Package) map[string][]namedConstant {
+	var consts []*types.Const
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if object, ok := scope.Lookup(name).(*types.Const); ok {
+			consts = append(consts, object)
+		}
+	}
+	sort.Slice(consts, func(i, j int) bool { return consts[i].Pos() < consts[j].Pos() })
+
+	result := map[string][]namedConstant{}
+	for _, object := range consts {
+		named, ok := object.Type().(*types.Named)
+		if !ok || named.Obj().Pkg() != pkg {
+			continue
+		}
+		if basic, ok := named.Underlying().(*types.Basic); !ok || basic.Info()&types.IsInteger == 0 {
+			continue
+		}
+
+		entry := namedConstant{name: object.Name()}
+		if value, exact := constant.Int64Val(object.Val()); exact {
+			entry.value, entry.text = uint64(value), strconv.FormatInt(value, 10)
+		} else if value, exact := constant.Uint64Val(object.Val()); exact {
+			entry.value, entry.text = value, strconv.FormatUint(value, 10)
+		} else {
+			continue
+		}
+		typeName := named.Obj().Name()
+		result[typeName] = append(result[typeName], entry)
+	}
+	return result
+}
+
+// substituteTable builds the table of the type; the first constant declared with a given value names it
+func substituteTable(values []namedConstant) map[string]string {
+	table := make(map[string]string, len(values))
+	for _, entry := range values {
+		if _, found := table[entry.text]; !found {
+			table[entry.text] = entry.name
+		}
+	}
+
+	if bitFlags(values) {
+		table[substituteFlags] = "true"
+	}
+	return table
+}
+
+// bitFlags reports whether the constants look like bit flags: the named masks (combinations of the flags) are allowed
+func bitFlags(values []namedConstant) bool {
+	var flags []uint64
+	var all uint64
+	seen := map[uint64]bool{}
+	for _, entry := range values {
+		if entry.value == 0 || seen[entry.value] || entry.value&(entry.value-1) != 0 {
+			continue
+		}
+		if strings.HasPrefix(entry.text, "-") {
+			return false
+		}
+		seen[entry.value] = true
+		flags = append(flags, entry.value)
+		all |= entry.value
+	}
+	for _, entry := range values {
+		if entry.value&(entry.value-1) != 0 && (entry.value&^all != 0 || strings.HasPrefix(entry.text, "-")) {
+			// neither a flag nor a mask of the flags
+			return false
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+
+	// 1, 2 (or 1, 2, 3 ...) is an enumeration rather than a set of flags
+	return len(flags) > 1 && flags[len(flags)-1] != uint64(len(flags))
+}
@@ -6,13 +6,16 @@ import (
 	"reflect"
 )
 
-func isInlinableValue(what reflect.Value) bool {
+func (m *mapper) isInlinableValue(what reflect.Value) bool {
 	if !what.IsZero() {
 		t := what.Type()
 
 		switch t.Kind() {
 		case reflect.Interface:
-			return isInlinableValue(what.Elem())
+			if dynamic := what.Elem().Type(); dynamic.Implements(errorType) && m.canCallMethodsOf(dynamic) {
+				return false // (see mapDynamicError)
+			}
+			return m.isInlinableValue(what.Elem())
 
 		case reflect.Pointer, reflect.Chan, reflect.Func, reflect.Struct, reflect.Map:
 			return false
@@ -23,7 +26,7 @@ func isInlinableValue(what reflect.Value) bool {
 		return inlinable
 	}
 
-	trace("[%s] is inlinable", what.Type().Kind())
+	traceTraversal("[%s] is inlinable", what.Type().Kind())
 	return inlinable
 }
 
@@ -48,12 +51,12 @@ func isEmptyGuarged(what reflect.Value, guard int) bool {
 	}
 
 	if !what.CanAddr() {
-		trace("non-addr %s\n", what.Type().Kind().String())
+		traceTraversal("non-addr %s\n", what.Type().Kind())
 	}
 
 	t := what.Type()
 	if !what.IsZero() {
-		trace("non-zero %s\n", t.Kind().String())
+		traceTraversal("non-zero %s\n", t.Kind())
 		switch t.Kind() {
 		case reflect.Pointer, reflect.Interface:
 			// todo: remember this element to avoid endless recursion
@@ -110,7 +113,7 @@ func isEmptyGuarged(what reflect.Value, guard int) bool {
 			return true
 		}
 
-		trace("zero: %s\n", t.Kind().String())
+		traceTraversal("zero: %s\n", t.Kind())
 		return true
 	}
 	return false
@@ -129,20 +132,32 @@ func getTypeName(typ reflect.Type) string {
 		name = typ.String()
 	}
 
-	if correction, found := typeNameMapping[name]; found {
+	if name == "Pointer" && typ.Kind() == reflect.UnsafePointer && typ.PkgPath() == "unsafe" {
+		name = "unsafe.Pointer"
+	} else if correction, found := typeNameMapping[name]; found {
 		name = correction
 	}
 
 	return name
 }
 
-func describe(text string, iVal reflect.Value) {
+// traceTraversal is the single place tracing the visited values (see optionTraceTraversal)
+func traceTraversal(format string, args ...interface{}) {
+	if optionTraceTraversal {
+		trace(format, args...)
+	}
+}
+
+func (m *mapper) describe(text string, iVal reflect.Value) {
+	if !optionTraceTraversal {
+		return
+	}
 	if !iVal.IsValid() {
 		trace("non valid value => probably result of nil pointer [%v]\n", iVal)
 		return
 	}
 
-	key := getNodeKey(iVal)
+	key := m.getNodeKey(iVal)
 	report("\t%s: ========= [%s] [%v] [%v] [key: %v]\n", text, iVal.Type().Kind().String(), iVal, iVal.String(), key)
 }
 
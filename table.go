@@ -5,6 +5,7 @@ package memory
 import (
 	"fmt"
 	"io"
+	"strings"
 	"time"
 )
 
@@ -14,7 +15,50 @@ import (
 		true:  "|",
 	}
 */
-func mTable(w io.Writer, nodes []*cnode, connections []connection, props info, comment string) {
+func mTable(w io.Writer, p *palette, nodes []*cnode, connections []connection, props info, comment string, clusters []string) {
+	out := func(format string, arg ...interface{}) {
+		fmt.Fprintf(w, format+"\n", arg...)
+	}
+
+	tableProlog(w, comment)
+
+	for _, node := range nodes {
+		if node.cluster == 0 {
+			node.write(w, p)
+		}
+	}
+
+	for index, name := range clusters {
+		if len(name) == 0 {
+			continue
+		}
+
+		cluster := p.properties(Cluster)
+		out("")
+		out("\tsubgraph cluster_%d {", index+1)
+		out("\t\tlabel=\"%s\"", dotString(name))
+		out("\t\tstyle=\"%s\"", cluster["style"])
+		out("\t\tcolor=\"%s\"", cluster["color"])
+		out("\t\tbgcolor=\"%s\"", cluster[background])
+		for _, node := range nodes {
+			if node.cluster == index+1 {
+				node.write(w, p)
+			}
+		}
+		out("\t}")
+	}
+
+	out("")
+	out("\t/* ------ connections ------ */")
+	for _, conn := range connections {
+		conn.write(w, p)
+	}
+
+	tableEpilog(w, p, props)
+}
+
+// tableProlog writes everything preceding the nodes
+func tableProlog(w io.Writer, comment string) {
 	out := func(format string, arg ...interface{}) {
 		fmt.Fprintf(w, format+"\n", arg...)
 	}
@@ -30,8 +74,8 @@ func mTable(w io.Writer, nodes []*cnode, connections []connection, props info, c
 	out("\trankdir=LR;")
 
 	if len(comment) > 0 {
-		out("\tlabel=\"%s\"", comment)
-		out("\ttooltip=\"%s\"", comment)
+		out("\tlabel=\"%s\"", dotString(comment))
+		out("\ttooltip=\"%s\"", dotString(comment))
 
 	}
 	out("\tbgcolor=\"%s\"", Options().ColorBackground)
@@ -47,21 +91,23 @@ func mTable(w io.Writer, nodes []*cnode, connections []connection, props info, c
 
 	out("")
 	out("\t/* ------ nodes ------ */")
-	for _, node := range nodes {
-		node.write(w)
-	}
+}
 
-	out("")
-	out("\t/* ------ connections ------ */")
-	for _, conn := range connections {
-		conn.write(w)
+// tableEpilog writes everything following the connections
+func tableEpilog(w io.Writer, p *palette, props info) {
+	out := func(format string, arg ...interface{}) {
+		fmt.Fprintf(w, format+"\n", arg...)
 	}
 
 	if !Options().SuppresInfo {
 		out("")
 		out("\t/* ------ info ------ */")
-		props.write(w)
+		props.write(w, p)
 	}
 
 	out("}")
 }
+
+func dotString(txt string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(txt, "\\", "\\\\"), "\"", "\\\"")
+}
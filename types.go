@@ -0,0 +1,181 @@
+// github.com/seamia/memory
+
+package memory
+
+import (
+	"io"
+	"reflect"
+)
+
+// MapType draws the schema of the supplied types (reflect.Type) or of the types of the supplied values (using the default config)
+func MapType(w io.Writer, is ...interface{}) error {
+	return defaultConfig().MapType(w, is...)
+}
+
+// MapType draws the schema of the supplied types (reflect.Type) or of the types of the supplied values:
+// a node per struct, interface and (non-predeclared) named type, a row per field (method, for interfaces)
+// and a connection per pointer, slice, map, chan, embedding or by value relationship
+func (c *Config) MapType(w io.Writer, is ...interface{}) error {
+	w, done, err := c.output(w)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	req := parseArguments(is)
+	m := c.newMapper(w, req)
+	for _, root := range req.roots {
+		typ := rootType(root.Value)
+		if typ == nil {
+			continue
+		}
+		m.roots = append(m.roots, rootEntry{root.Name, m.mapType(typ)})
+	}
+
+	m.write(w)
+	return nil
+}
+
+// rootType returns the supplied reflect.Type or the type of the supplied value, without the pointers
+func rootType(value interface{}) reflect.Type {
+	typ, ok := value.(reflect.Type)
+	if !ok {
+		typ = reflect.TypeOf(value)
+	}
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// ownsNode reports whether the type gets a node of its own in the schema
+func ownsNode(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Interface:
+		return true
+	}
+	return len(typ.Name()) > 0 && len(typ.PkgPath()) > 0
+}
+
+// referenced returns the types (having nodes of their own) the type refers to, along with the style of the reference
+func referenced(typ reflect.Type, style connectionStyle) (targets []reflect.Type, styles []connectionStyle) {
+	if ownsNode(typ) {
+		if style == connInner && typ.Kind() == reflect.Interface {
+			style = connPointer
+		}
+		return []reflect.Type{typ}, []connectionStyle{style}
+	}
+
+	add := func(elem reflect.Type, kind connectionStyle) {
+		if style != connInner {
+			// the outermost constructor defines the style
+			kind = style
+		}
+		t, s := referenced(elem, kind)
+		targets, styles = append(targets, t...), append(styles, s...)
+	}
+
+	switch typ.Kind() {
+	case reflect.Pointer:
+		add(typ.Elem(), connPointer)
+	case reflect.Slice, reflect.Array:
+		add(typ.Elem(), connArray)
+	case reflect.Chan:
+		add(typ.Elem(), connDefault)
+	case reflect.Map:
+		add(typ.Key(), connDefault)
+		add(typ.Elem(), connDefault)
+	case reflect.Func:
+		for index := 0; index < typ.NumIn(); index++ {
+			add(typ.In(index), connDefault)
+		}
+		for index := 0; index < typ.NumOut(); index++ {
+			add(typ.Out(index), connDefault)
+		}
+	}
+	return targets, styles
+}
+
+// mapType adds the node describing the type (and the nodes of the types it refers to)
+func (m *mapper) mapType(typ reflect.Type) nodeID {
+	if !ownsNode(typ) {
+		// e.g. []*T: draw T
+		if targets, _ := referenced(typ, connInner); len(targets) > 0 {
+			return m.mapType(targets[0])
+		}
+		id, _ := m.typeID(typ)
+		m.addNode(createNode(id, getTypeName(typ), typ.Kind().String()))
+		return id
+	}
+
+	id, fresh := m.typeID(typ)
+	if !fresh {
+		return id
+	}
+
+	// style: of the reference (connInner - by value)
+	link := func(port string, to reflect.Type, tooltip string, style connectionStyle) {
+		targets, styles := referenced(to, style)
+		for index, target := range targets {
+			m.addConnection(id, port, m.mapType(target), tooltip, styles[index])
+		}
+	}
+
+	var snode *cnode
+	switch typ.Kind() {
+	case reflect.Struct:
+		snode = createNode(id, m.getStructTypeName(typ), "struct: "+typ.String())
+		for index := 0; index < typ.NumField(); index++ {
+			field := typ.Field(index)
+			name := field.Name
+			if field.Anonymous {
+				name = "(embedded) " + name
+			}
+			snode.addCells(cell{port: getStructRef(index), name: name, kind: Key}, cell{port: getStructOutgoing(index), name: getTypeName(field.Type), kind: Type})
+			link(getStructOutgoing(index), field.Type, field.Name, connInner)
+		}
+
+	case reflect.Interface:
+		snode = createNode(id, getTypeName(typ), "interface: "+typ.String())
+		for index := 0; index < typ.NumMethod(); index++ {
+			method := typ.Method(index)
+			snode.addCells(cell{port: getStructRef(index), name: method.Name, kind: Key}, cell{port: getStructOutgoing(index), name: method.Type.String(), kind: Type})
+			link(getStructOutgoing(index), method.Type, method.Name, connDefault)
+		}
+		if typ.NumMethod() == 0 {
+			snode.addField("", "(no methods)", Blank)
+		}
+
+	default:
+		// named, non-struct type: e.g. type Color int
+		snode = createNode(id, getTypeName(typ), typ.Kind().String()+": "+typ.String())
+		snode.addCells(cell{port: getStructRef(0), name: "underlying", kind: Key}, cell{port: getStructOutgoing(0), name: unnamed(typ), kind: Type})
+		switch typ.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Chan:
+			link(getStructOutgoing(0), typ.Elem(), "", kind2style(typ.Kind()))
+		case reflect.Map:
+			link(getStructOutgoing(0), typ.Key(), "key", connDefault)
+			link(getStructOutgoing(0), typ.Elem(), "value", connDefault)
+		}
+	}
+
+	m.addNode(snode)
+	return id
+}
+
+// unnamed returns the description of the underlying type (of the named composite type)
+func unnamed(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return "*" + getTypeName(typ.Elem())
+	case reflect.Slice:
+		return "[]" + getTypeName(typ.Elem())
+	case reflect.Array:
+		return "[" + str(typ.Len()) + "]" + getTypeName(typ.Elem())
+	case reflect.Chan:
+		return "chan " + getTypeName(typ.Elem())
+	case reflect.Map:
+		return "map[" + getTypeName(typ.Key()) + "]" + getTypeName(typ.Elem())
+	}
+	return typ.Kind().String()
+}